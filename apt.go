@@ -0,0 +1,24 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+)
+
+// openAptChangelog runs "apt-get changelog" for pkg and returns its
+// stdout. apt-get fetches the changelog with the proxy and the Acquire
+// settings in the apt configuration. pkg can also be in the form of
+// "pkg=version" or "pkg/release".
+func openAptChangelog(pkg string) (io.ReadCloser, error) {
+	cmd := exec.Command("apt-get", "-qq", "changelog", pkg)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandReadCloser{ReadCloser: stdout, cmd: cmd, desc: "apt-get command", ignoreSIGPIPE: true}, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Content atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// atomWriter writes matched entries as an Atom feed. Entries are kept
+// in entries as JSON until Close, which writes the feed with the date
+// of the newest entry. IDs are URNs made of the package name and the
+// version, so that feed readers see the same ID for an entry in every run.
+type atomWriter struct {
+	w       io.Writer
+	feed    atomFeed
+	entries *spool
+}
+
+func newAtomWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &atomWriter{w: w, entries: newSpool(opts.maxMemory)}
+}
+
+func (a *atomWriter) WriteEntry(entry Entry) error {
+	var content strings.Builder
+	if _, err := entry.WriteTo(&content); err != nil {
+		return err
+	}
+	if a.feed.ID == "" {
+		a.feed.ID = "urn:" + appName + ":" + entry.Package
+		a.feed.Title = entry.Package + " changelog"
+	}
+	// Entries with a date which cannot be parsed are dated as the feed
+	// in Close, and have the date as written in the content.
+	updated := ""
+	if !entry.Date.IsZero() {
+		updated = entry.Date.Format(time.RFC3339)
+		if utc := entry.Date.UTC().Format(time.RFC3339); utc > a.feed.Updated {
+			a.feed.Updated = utc
+		}
+	}
+	data, err := json.Marshal(atomEntry{
+		ID:      "urn:" + appName + ":" + entry.Package + ":" + entry.Version,
+		Title:   entry.Package + " " + entry.Version,
+		Updated: updated,
+		Author:  atomAuthor{Name: entry.MaintainerName, Email: entry.EmailAddress},
+		Content: atomContent{Type: "text", Text: content.String()},
+	})
+	if err != nil {
+		return err
+	}
+	return a.entries.add(data)
+}
+
+func (a *atomWriter) Close() error {
+	defer a.entries.close()
+	if a.feed.ID == "" {
+		a.feed.ID = "urn:" + appName
+		a.feed.Title = "changelog"
+	}
+	if a.feed.Updated == "" {
+		a.feed.Updated = time.Now().UTC().Format(time.RFC3339)
+	}
+	// The feed is written without entries up to its end tag, which is
+	// written after the entries.
+	var head bytes.Buffer
+	head.WriteString(xml.Header)
+	enc := xml.NewEncoder(&head)
+	enc.Indent("", "  ")
+	if err := enc.Encode(a.feed); err != nil {
+		return err
+	}
+	const endTag = "</feed>"
+	if _, err := a.w.Write(bytes.TrimSuffix(head.Bytes(), []byte(endTag))); err != nil {
+		return err
+	}
+	for i := 0; i < a.entries.len(); i++ {
+		data, err := a.entries.get(i)
+		if err != nil {
+			return err
+		}
+		var entry atomEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		if entry.Updated == "" {
+			entry.Updated = a.feed.Updated
+		}
+		enc := xml.NewEncoder(a.w)
+		enc.Indent("  ", "  ")
+		if err := enc.EncodeElement(entry, xml.StartElement{Name: xml.Name{Local: "entry"}}); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(a.w, "\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(a.w, endTag+"\n")
+	return err
+}
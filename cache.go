@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// httpCacheDir is the directory to cache fetched changelogs in, or empty
+// to disable the cache.
+var httpCacheDir string
+
+func defaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appName), nil
+}
+
+// httpCacheMeta is saved next to a cached response body to revalidate it.
+type httpCacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// httpGetCached is httpGet with the response body cached in httpCacheDir
+// keyed by url. A cached body is revalidated with If-None-Match and
+// If-Modified-Since, and read from the cache if the server responds with
+// 304 Not Modified. Responses without ETag nor Last-Modified are not
+// cached, nor are responses to requests with header, like the credentials
+// of private mirrors and PPAs, since they may depend on the header and
+// should not be left on disk. The cache is only an optimization, so
+// errors writing it are reported as warnings.
+func httpGetCached(url string, header http.Header) (io.ReadCloser, error) {
+	if httpCacheDir == "" || len(header) > 0 {
+		return httpGet(url, header)
+	}
+	sum := sha256.Sum256([]byte(url))
+	bodyPath := filepath.Join(httpCacheDir, hex.EncodeToString(sum[:]))
+	metaPath := bodyPath + ".json"
+
+	reqHeader := http.Header{}
+	var meta httpCacheMeta
+	if data, err := os.ReadFile(metaPath); err == nil && json.Unmarshal(data, &meta) == nil && meta.URL == url {
+		if _, err := os.Stat(bodyPath); err == nil {
+			if meta.ETag != "" {
+				reqHeader.Set("If-None-Match", meta.ETag)
+			}
+			if meta.LastModified != "" {
+				reqHeader.Set("If-Modified-Since", meta.LastModified)
+			}
+		}
+	}
+
+	resp, err := httpDo(url, reqHeader)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		resp.Body.Close()
+		return os.Open(bodyPath)
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, &httpStatusError{URL: url, Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+	meta = httpCacheMeta{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if meta.ETag == "" && meta.LastModified == "" {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeHTTPCache(bodyPath, metaPath, body, meta); err != nil {
+		warnAt("", 0, "cache_error", "cannot cache "+url+": "+err.Error())
+	}
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
+
+// writeHTTPCache writes body and meta to the cache readable only by the
+// user, since changelogs of private mirrors may be cached.
+func writeHTTPCache(bodyPath, metaPath string, body []byte, meta httpCacheMeta) error {
+	if err := os.MkdirAll(httpCacheDir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := writeCacheFile(bodyPath, body); err != nil {
+		return err
+	}
+	return writeCacheFile(metaPath, data)
+}
+
+func writeCacheFile(filename string, data []byte) error {
+	f, err := createAtomicFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0o600); err != nil {
+		f.Abort()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
@@ -0,0 +1,213 @@
+package changelog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultWrapWidth is the column which lines written by Writer are
+// wrapped at by default.
+const DefaultWrapWidth = 80
+
+// Builder builds a new entry. Errors of the methods are returned by
+// Finalize.
+type Builder struct {
+	entry Entry
+	err   error
+}
+
+// NewEntry returns a builder of an entry of version of pkg for
+// distributions with "urgency=medium".
+func NewEntry(pkg, version, distributions string) *Builder {
+	b := &Builder{entry: Entry{
+		Package:       pkg,
+		Version:       version,
+		Distributions: distributions,
+	}}
+	b.entry.SetMetadata("urgency=medium")
+	return b
+}
+
+// Urgency sets the urgency like "low" or "high".
+func (b *Builder) Urgency(urgency string) *Builder {
+	b.entry.SetMetadata("urgency=" + urgency)
+	return b
+}
+
+// Maintainer sets the name and the email address of the maintainer.
+func (b *Builder) Maintainer(name, email string) *Builder {
+	b.entry.MaintainerName = name
+	b.entry.EmailAddress = email
+	return b
+}
+
+// Date sets the date of the entry, which is the time of Finalize if it is
+// not set.
+func (b *Builder) Date(date time.Time) *Builder {
+	b.entry.Date = date
+	return b
+}
+
+// AddChange adds a change with summary.
+func (b *Builder) AddChange(summary string) *Builder {
+	b.entry.Changes = append(b.entry.Changes, Change{Summary: summary})
+	return b
+}
+
+// AddDetail adds a detail with lines to the last added change.
+func (b *Builder) AddDetail(lines ...string) *Builder {
+	if len(b.entry.Changes) == 0 {
+		if b.err == nil {
+			b.err = errors.New("changelog: detail added before any change")
+		}
+		return b
+	}
+	change := &b.entry.Changes[len(b.entry.Changes)-1]
+	change.Details = append(change.Details, Detail{Lines: lines})
+	return b
+}
+
+// Finalize validates and returns the entry. The package, the version, the
+// distributions, the maintainer and at least one change are required, and
+// texts must not have newlines.
+func (b *Builder) Finalize() (Entry, error) {
+	if b.err != nil {
+		return Entry{}, b.err
+	}
+	e := b.entry
+	switch {
+	case e.Package == "" || strings.ContainsAny(e.Package, " ()"):
+		return Entry{}, fmt.Errorf("changelog: invalid package %q", e.Package)
+	case e.Distributions == "" || strings.ContainsAny(e.Distributions, ";\n"):
+		return Entry{}, fmt.Errorf("changelog: invalid distributions %q", e.Distributions)
+	case e.MaintainerName == "" || e.EmailAddress == "" || strings.ContainsAny(e.MaintainerName+e.EmailAddress, "<>\n"):
+		return Entry{}, fmt.Errorf("changelog: invalid maintainer %q <%s>", e.MaintainerName, e.EmailAddress)
+	case len(e.Changes) == 0:
+		return Entry{}, errors.New("changelog: no changes")
+	}
+	if err := ValidateVersion(e.Version); err != nil {
+		return Entry{}, fmt.Errorf("changelog: %s", err)
+	}
+	for _, change := range e.Changes {
+		if strings.TrimSpace(change.Summary) == "" || strings.Contains(change.Summary, "\n") {
+			return Entry{}, fmt.Errorf("changelog: invalid change summary %q", change.Summary)
+		}
+		for _, detail := range change.Details {
+			for _, line := range detail.Lines {
+				if strings.Contains(line, "\n") {
+					return Entry{}, fmt.Errorf("changelog: invalid detail line %q", line)
+				}
+			}
+		}
+	}
+	if e.Date.IsZero() {
+		e.Date = time.Now()
+	}
+	// Copy changes so that the builder can be reused.
+	e.Changes = append([]Change(nil), e.Changes...)
+	return e, nil
+}
+
+// Writer writes entries in the debian/changelog format with summaries and
+// detail lines wrapped at Width, with a blank line between entries.
+type Writer struct {
+	// Width is the column to wrap lines at, or 0 for no wrapping.
+	Width int
+
+	w     io.Writer
+	count int
+}
+
+// NewWriter returns a writer to w wrapping lines at DefaultWrapWidth.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{Width: DefaultWrapWidth, w: w}
+}
+
+// WriteEntry writes e after a blank line unless it is the first entry.
+func (w *Writer) WriteEntry(e Entry) error {
+	var b strings.Builder
+	if w.count > 0 {
+		b.WriteString("\n")
+	}
+	w.count++
+	fmt.Fprintf(&b, "%s (%s) %s; %s\n\n", e.Package, e.Version, e.Distributions, e.Metadata)
+	for _, line := range e.ExtraLines {
+		b.WriteString(line + "\n")
+	}
+	for _, change := range e.Changes {
+		for _, line := range change.LeadingLines {
+			b.WriteString(line + "\n")
+		}
+		for _, line := range WrapText(ChangePrefix, ChangeTailPrefix, change.Summary, w.Width) {
+			b.WriteString(line + "\n")
+		}
+		for _, detail := range change.Details {
+			for i, line := range detail.Lines {
+				prefix, restPrefix := DetailHeadPrefix, DetailTailPrefix
+				if i > 0 {
+					// Keep lines nested deeper than the detail so.
+					text := strings.TrimLeft(line, " ")
+					prefix = DetailTailPrefix + line[:len(line)-len(text)]
+					restPrefix = prefix
+					line = text
+				}
+				for _, line := range WrapText(prefix, restPrefix, line, w.Width) {
+					b.WriteString(line + "\n")
+				}
+			}
+		}
+		for _, line := range change.ExtraLines {
+			b.WriteString(line + "\n")
+		}
+	}
+	if len(e.Changes) > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, MaintainerLinePrefix+"%s <%s>  %s\n", e.MaintainerName, e.EmailAddress, e.DateString())
+	_, err := io.WriteString(w.w, b.String())
+	return err
+}
+
+// WrapText splits text at spaces into lines no longer than width including
+// prefixes. The first line starts with firstPrefix and the rest start with
+// restPrefix. A word longer than width is put on its own line. Lines are
+// never broken before a bullet-like word "*", "-" or "+", which would make
+// the rest of the text parsed as a new change or detail.
+func WrapText(firstPrefix, restPrefix, text string, width int) []string {
+	if width <= 0 || utf8.RuneCountInString(firstPrefix+text) <= width {
+		return []string{firstPrefix + text}
+	}
+	var words []string
+	for _, word := range strings.Fields(text) {
+		if isBulletWord(word) && len(words) > 0 {
+			words[len(words)-1] += " " + word
+		} else {
+			words = append(words, word)
+		}
+	}
+	var lines []string
+	prefix := firstPrefix
+	line := ""
+	for _, word := range words {
+		if line != "" && utf8.RuneCountInString(prefix+line+" "+word) > width {
+			lines = append(lines, prefix+line)
+			prefix = restPrefix
+			line = ""
+		}
+		if line == "" {
+			line = word
+		} else {
+			line += " " + word
+		}
+	}
+	return append(lines, prefix+line)
+}
+
+// isBulletWord reports whether word is a bullet of changes or details.
+func isBulletWord(word string) bool {
+	return word == "*" || word == "-" || word == "+"
+}
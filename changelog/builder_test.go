@@ -0,0 +1,68 @@
+package changelog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	date := time.Date(2024, 8, 30, 14, 4, 45, 0, time.FixedZone("", 2*60*60))
+	entries := []Entry{}
+	for _, b := range []*Builder{
+		NewEntry("linux", "6.8.0-45.45", "noble").
+			Maintainer("Stefan Bader", "stefan.bader@canonical.com").Date(date).
+			AddChange("Fix the frobnicator so that it works with aaa - bbb ccc and with the other thing * too + more").
+			AddDetail("mm: short detail", "- nested line", "  deeper nested line").
+			AddDetail("second detail").
+			AddChange("A change whose summary is long enough to be wrapped onto the next line - twice or more, which is - quite - long"),
+		NewEntry("foo", "1.0-1", "unstable").Urgency("low").
+			Maintainer("John Doe", "john@example.com").Date(date).
+			AddChange("Short change"),
+	} {
+		e, err := b.Finalize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+
+	for _, width := range []int{0, 30, DefaultWrapWidth} {
+		var b strings.Builder
+		w := NewWriter(&b)
+		w.Width = width
+		for _, e := range entries {
+			if err := w.WriteEntry(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := Parse(strings.NewReader(b.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, w := entriesJSON(t, got), entriesJSON(t, entries); g != w {
+			t.Errorf("width %d: written entries are parsed differently\nwritten:\n%s\ngot: %s\nwant: %s", width, b.String(), g, w)
+		}
+	}
+}
+
+func entriesJSON(t *testing.T, entries []Entry) string {
+	t.Helper()
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWrapTextDoesNotStartLinesWithBullets(t *testing.T) {
+	text := "aaa - bbb * ccc + ddd - eee"
+	for width := 1; width <= len(ChangePrefix+text); width++ {
+		for _, line := range WrapText(ChangePrefix, ChangeTailPrefix, text, width)[1:] {
+			if word := strings.Fields(line)[0]; isBulletWord(word) {
+				t.Errorf("width %d: line %q starts with a bullet", width, line)
+			}
+		}
+	}
+}
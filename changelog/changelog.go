@@ -0,0 +1,194 @@
+// Package changelog parses, filters and formats Debian changelogs like the
+// changelogs of Ubuntu Linux kernels.
+//
+// https://manpages.debian.org/testing/dpkg-dev/deb-changelog.5.en.html
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Entry is an entry of a changelog from the heading line to the trailer
+// line.
+type Entry struct {
+	Package        string    `json:"package" xml:"package"`
+	Version        string    `json:"version" xml:"version"`
+	Distributions  string    `json:"distributions" xml:"distributions"`
+	Metadata       string    `json:"metadata" xml:"metadata"`
+	MaintainerName string    `json:"maintainer_name" xml:"maintainer_name"`
+	EmailAddress   string    `json:"email_address" xml:"email_address"`
+	Date           time.Time `json:"date" xml:"date"`
+	Changes        []Change  `json:"changes" xml:"changes>change"`
+	// ExtraLines are unrecognized lines before the first change like
+	// "  [ Ubuntu: 6.8.0-45.45 ]", kept by the lenient mode of Parser.
+	ExtraLines []string `json:"extra_lines,omitempty" xml:"extra_lines>line,omitempty"`
+	// RawDate is the date of the trailer line which cannot be parsed, in
+	// which case Date is zero.
+	RawDate string `json:"raw_date,omitempty" xml:"raw_date,omitempty"`
+	// MetadataFields are the key=value pairs of Metadata separated by
+	// commas, with keys in lower case. Urgency is the value of "urgency"
+	// in lower case without comments like "high (security fixes)". They
+	// are set with SetMetadata.
+	MetadataFields map[string]string `json:"metadata_fields,omitempty" xml:"-"`
+	Urgency        string            `json:"urgency,omitempty" xml:"urgency,omitempty"`
+	// Filename is the input the entry is read from, which is set by
+	// callers reading multiple inputs.
+	Filename string `json:"filename,omitempty" xml:"filename,omitempty"`
+	// BinaryPackage is the package owning the doc directory of Filename,
+	// which is set by callers reading installed changelogs.
+	BinaryPackage string `json:"binary_package,omitempty" xml:"binary_package,omitempty"`
+
+	// raw is the original text from the heading line to the trailer line,
+	// rawSeparator is the blank lines and comments between the previous
+	// entry and the heading line, and rawTrailer is the text after the
+	// trailer line of the last entry.
+	raw          string
+	rawSeparator string
+	rawTrailer   string
+}
+
+// Change is a change in an entry, a line starting with "  * " and the
+// following lines of its details.
+type Change struct {
+	// Summary is the text of the "  * " line, joined with its
+	// continuation lines indented with four spaces by a space.
+	Summary string   `json:"summary" xml:"summary"`
+	Details []Detail `json:"details" xml:"details>detail"`
+	// LeadingLines are unrecognized lines before the summary like
+	// "  [ John Doe ]" naming the authors of the following changes, and
+	// ExtraLines are those after the summary or the details of the last
+	// change. They are kept by the lenient mode of Parser.
+	LeadingLines []string `json:"leading_lines,omitempty" xml:"leading_lines>line,omitempty"`
+	ExtraLines   []string `json:"extra_lines,omitempty" xml:"extra_lines>line,omitempty"`
+}
+
+// Detail is a line starting with "    - " and its continuation lines.
+type Detail struct {
+	Lines []string `json:"lines" xml:"line"`
+}
+
+// Prefixes of lines in entries written by String, WriteTo and Writer.
+// The parser also accepts changes bulleted with "*", "-" or "+" at other
+// indentation like "   * " or "  - ", and details bulleted deeper than
+// the changes.
+const (
+	ChangePrefix         = "  * "
+	ChangeTailPrefix     = "    "
+	DetailHeadPrefix     = "    - "
+	DetailTailPrefix     = "      "
+	MaintainerLinePrefix = " -- "
+)
+
+// DateFormat is the format of dates in trailer lines.
+const DateFormat = "Mon, 02 Jan 2006 15:04:05 -0700"
+
+// Raw returns the original text of the entry from the heading line to
+// the trailer line, or an empty string if the entry is not parsed.
+func (e *Entry) Raw() string {
+	return e.raw
+}
+
+// RawSeparator returns the original blank lines and comments between the
+// previous entry, or the start of the input, and the heading line.
+func (e *Entry) RawSeparator() string {
+	return e.rawSeparator
+}
+
+// RawTrailer returns the original text after the trailer line if the
+// entry is the last one of the input, like blank lines and comments.
+// Writing RawSeparator, Raw and RawTrailer of all entries reproduces the
+// input.
+func (e *Entry) RawTrailer() string {
+	return e.rawTrailer
+}
+
+// DateString returns Date in DateFormat, or RawDate if Date cannot be
+// parsed.
+func (e *Entry) DateString() string {
+	if e.Date.IsZero() && e.RawDate != "" {
+		return e.RawDate
+	}
+	return e.Date.Format(DateFormat)
+}
+
+// SetMetadata sets Metadata to metadata like "urgency=medium" and sets
+// MetadataFields and Urgency parsed from it.
+func (e *Entry) SetMetadata(metadata string) {
+	e.Metadata = metadata
+	e.MetadataFields = nil
+	e.Urgency = ""
+	for _, field := range strings.Split(metadata, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			continue
+		}
+		if e.MetadataFields == nil {
+			e.MetadataFields = make(map[string]string)
+		}
+		value = strings.TrimSpace(value)
+		e.MetadataFields[key] = value
+		if key == "urgency" {
+			if words := strings.Fields(value); len(words) > 0 {
+				e.Urgency = strings.ToLower(words[0])
+			}
+		}
+	}
+}
+
+// String returns the entry in the changelog format without the blank
+// lines around changes. Use WriteTo or MarshalText for the format which
+// can be parsed again.
+func (e *Entry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s) %s; %s\n", e.Package, e.Version, e.Distributions, e.Metadata)
+	for _, line := range e.ExtraLines {
+		b.WriteString(line + "\n")
+	}
+	for _, change := range e.Changes {
+		for _, line := range change.LeadingLines {
+			b.WriteString(line + "\n")
+		}
+		fmt.Fprintf(&b, ChangePrefix+"%s\n", change.Summary)
+		for _, detail := range change.Details {
+			for i, line := range detail.Lines {
+				prefix := DetailHeadPrefix
+				if i > 0 {
+					prefix = DetailTailPrefix
+				}
+				fmt.Fprintf(&b, prefix+"%s\n", line)
+			}
+		}
+		for _, line := range change.ExtraLines {
+			b.WriteString(line + "\n")
+		}
+	}
+	fmt.Fprintf(&b, MaintainerLinePrefix+"%s <%s> %s", e.MaintainerName, e.EmailAddress, e.DateString())
+	return b.String()
+}
+
+// Matches returns whether re matches the summary or a detail line.
+func (c *Change) Matches(re *regexp.Regexp) bool {
+	if re.MatchString(c.Summary) {
+		return true
+	}
+	for _, detail := range c.Details {
+		if detail.Matches(re) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns whether re matches any of the lines.
+func (d *Detail) Matches(re *regexp.Regexp) bool {
+	for _, line := range d.Lines {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
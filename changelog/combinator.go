@@ -0,0 +1,117 @@
+package changelog
+
+import (
+	"regexp"
+	"time"
+)
+
+// FilterFunc is an adapter to use a function as a Filter.
+type FilterFunc func(entry Entry, change Change) bool
+
+// MatchChange calls f(entry, change).
+func (f FilterFunc) MatchChange(entry Entry, change Change) bool {
+	return f(entry, change)
+}
+
+type andFilter []Filter
+
+// And returns a filter selecting changes selected by all of filters. It
+// shows details shown by any of filters which are DetailMatchers, or all
+// details if there are none of them.
+func And(filters ...Filter) Filter {
+	return andFilter(filters)
+}
+
+func (a andFilter) MatchChange(entry Entry, change Change) bool {
+	for _, f := range a {
+		if !f.MatchChange(entry, change) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a andFilter) MatchDetail(entry Entry, change Change, detail Detail) bool {
+	found := false
+	for _, f := range a {
+		if m, ok := f.(DetailMatcher); ok {
+			if m.MatchDetail(entry, change, detail) {
+				return true
+			}
+			found = true
+		}
+	}
+	return !found
+}
+
+type orFilter []Filter
+
+// Or returns a filter selecting changes selected by any of filters. It
+// shows details shown by any of filters which select the change.
+func Or(filters ...Filter) Filter {
+	return orFilter(filters)
+}
+
+func (o orFilter) MatchChange(entry Entry, change Change) bool {
+	for _, f := range o {
+		if f.MatchChange(entry, change) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o orFilter) MatchDetail(entry Entry, change Change, detail Detail) bool {
+	for _, f := range o {
+		if f.MatchChange(entry, change) && matchDetail(f, entry, change, detail) {
+			return true
+		}
+	}
+	return false
+}
+
+// Not returns a filter selecting changes not selected by filter, with all
+// details.
+func Not(filter Filter) Filter {
+	return FilterFunc(func(entry Entry, change Change) bool {
+		return !filter.MatchChange(entry, change)
+	})
+}
+
+type regexpFilter struct {
+	re *regexp.Regexp
+}
+
+// Regexp returns a filter selecting changes whose summary or detail lines
+// match re. It shows the details matching re, or all details if only the
+// summary matches.
+func Regexp(re *regexp.Regexp) Filter {
+	return regexpFilter{re: re}
+}
+
+func (r regexpFilter) MatchChange(entry Entry, change Change) bool {
+	return change.Matches(r.re)
+}
+
+func (r regexpFilter) MatchDetail(entry Entry, change Change, detail Detail) bool {
+	return r.re.MatchString(change.Summary) || detail.Matches(r.re)
+}
+
+// DateRange returns a filter selecting changes of entries dated from since
+// inclusive to until exclusive. A zero since or until is unbounded.
+func DateRange(since, until time.Time) Filter {
+	return FilterFunc(func(entry Entry, _ Change) bool {
+		return (since.IsZero() || !entry.Date.Before(since)) &&
+			(until.IsZero() || entry.Date.Before(until))
+	})
+}
+
+// VersionRange returns a filter selecting changes of entries whose
+// versions are from min inclusive to max exclusive, compared with
+// CompareVersions. An empty min or max is unbounded.
+func VersionRange(min, max string) Filter {
+	return FilterFunc(func(entry Entry, _ Change) bool {
+		return (min == "" || CompareVersions(entry.Version, min) >= 0) &&
+			(max == "" || CompareVersions(entry.Version, max) < 0)
+	})
+}
@@ -0,0 +1,74 @@
+package changelog
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ParseErrorKind is the kind of a malformed line reported by ParseError.
+type ParseErrorKind int
+
+const (
+	// BadHeader is an entry heading line not in the form of
+	// "package (version) distributions; metadata".
+	BadHeader ParseErrorKind = iota + 1
+	// BadMaintainerLine is a trailer line not in the form of
+	// " -- maintainer name <email address>  date".
+	BadMaintainerLine
+	// BadDate is a trailer line with a date which cannot be parsed.
+	BadDate
+	// UnrecognizedLine is a line which does not fit the grammar, which is
+	// an error only in the strict mode of Parser.
+	UnrecognizedLine
+)
+
+func (k ParseErrorKind) String() string {
+	switch k {
+	case BadHeader:
+		return "bad header"
+	case BadMaintainerLine:
+		return "bad maintainer line"
+	case BadDate:
+		return "bad date"
+	case UnrecognizedLine:
+		return "unrecognized line"
+	}
+	return "ParseErrorKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// ParseError is an error of a malformed line in a changelog.
+type ParseError struct {
+	// Filename is the name of the changelog, which is set by ParseFile
+	// and is empty otherwise.
+	Filename string
+	// Line is the 1-based line number, or zero if the line is parsed
+	// alone like with ParseEntryLine.
+	Line int
+	// Text is the malformed line without the newline.
+	Text string
+	Kind ParseErrorKind
+	// Err is the underlying error like the error of time.Parse for
+	// BadDate, or nil.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	var pos string
+	switch {
+	case e.Filename != "" && e.Line > 0:
+		pos = e.Filename + ":" + strconv.Itoa(e.Line) + ": "
+	case e.Filename != "":
+		pos = e.Filename + ": "
+	case e.Line > 0:
+		pos = "line " + strconv.Itoa(e.Line) + ": "
+	}
+	msg := fmt.Sprintf("%s%s: %q", pos, e.Kind, e.Text)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
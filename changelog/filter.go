@@ -0,0 +1,170 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Filter selects changes of entries.
+type Filter interface {
+	// MatchChange returns whether change of entry is selected.
+	MatchChange(entry Entry, change Change) bool
+}
+
+// DetailMatcher is implemented by filters which select details of the
+// changes they select. All details are shown for filters which do not
+// implement it.
+type DetailMatcher interface {
+	// MatchDetail returns whether detail of change of entry is shown.
+	MatchDetail(entry Entry, change Change, detail Detail) bool
+}
+
+// PatternFilter selects changes with regular expressions matched against
+// the summary and the detail lines. The zero PatternFilter selects all
+// changes with all details.
+type PatternFilter struct {
+	Patterns []*regexp.Regexp
+	// All requires every pattern to match a line of a change, instead
+	// of any of them.
+	All bool
+	// Excludes removes changes matching any of them in the summary or
+	// details even if they match Patterns.
+	Excludes []*regexp.Regexp
+	// Predicate must also be satisfied by changes if it is not nil.
+	Predicate func(entry *Entry, change *Change) bool
+	// TextPatterns are regular expressions used in Predicate, which
+	// select details to show like Patterns.
+	TextPatterns []*regexp.Regexp
+	// MatchHeader makes Patterns and Excludes also match the heading
+	// line and the maintainer of entries, selecting all their changes.
+	MatchHeader bool
+	// SummaryPatterns show all details of changes whose summary matches
+	// any of them.
+	SummaryPatterns []*regexp.Regexp
+}
+
+// MatchChange returns whether change of entry is selected.
+func (f *PatternFilter) MatchChange(entry Entry, change Change) bool {
+	for _, re := range f.Excludes {
+		if f.matches(&entry, change, re) {
+			return false
+		}
+	}
+	if f.Predicate != nil && !f.Predicate(&entry, &change) {
+		return false
+	}
+	if len(f.Patterns) == 0 {
+		return true
+	}
+	for _, re := range f.Patterns {
+		if matched := f.matches(&entry, change, re); matched != f.All {
+			return matched
+		}
+	}
+	return f.All
+}
+
+// matches returns whether re matches change, or the header of entry with
+// MatchHeader.
+func (f *PatternFilter) matches(entry *Entry, change Change, re *regexp.Regexp) bool {
+	return change.Matches(re) || f.MatchHeader && headerMatches(entry, re)
+}
+
+// headerMatches returns whether re matches the heading line of entry like
+// "linux (6.8.0-45.45) noble; urgency=medium", or "name <email>" of the
+// maintainer.
+func headerMatches(entry *Entry, re *regexp.Regexp) bool {
+	return re.MatchString(fmt.Sprintf("%s (%s) %s; %s", entry.Package, entry.Version, entry.Distributions, entry.Metadata)) ||
+		re.MatchString(entry.MaintainerName+" <"+entry.EmailAddress+">")
+}
+
+// MatchDetail returns whether detail of a selected change of entry is
+// shown, which is when any of Patterns or TextPatterns matches it. All
+// details are shown if there are no such patterns, with MatchHeader if
+// any of Patterns matches the header, or if any of SummaryPatterns
+// matches the summary.
+func (f *PatternFilter) MatchDetail(entry Entry, change Change, detail Detail) bool {
+	for _, re := range f.SummaryPatterns {
+		if re.MatchString(change.Summary) {
+			return true
+		}
+	}
+	if f.MatchHeader {
+		for _, re := range f.Patterns {
+			if headerMatches(&entry, re) {
+				return true
+			}
+		}
+	}
+	patterns := f.HighlightPatterns()
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, re := range patterns {
+		if detail.Matches(re) {
+			return true
+		}
+	}
+	return false
+}
+
+// HighlightPatterns returns the regular expressions matching substrings
+// which made changes selected.
+func (f *PatternFilter) HighlightPatterns() []*regexp.Regexp {
+	return append(f.Patterns[:len(f.Patterns):len(f.Patterns)], f.TextPatterns...)
+}
+
+// FilterEntry returns a copy of entry which has only changes selected by
+// filter, with their details shown by it. It returns false if no changes
+// are selected.
+func FilterEntry(entry Entry, filter Filter) (Entry, bool) {
+	matchedEntry := Entry{
+		Package:        entry.Package,
+		Version:        entry.Version,
+		Distributions:  entry.Distributions,
+		Metadata:       entry.Metadata,
+		MetadataFields: entry.MetadataFields,
+		Urgency:        entry.Urgency,
+		MaintainerName: entry.MaintainerName,
+		EmailAddress:   entry.EmailAddress,
+		Date:           entry.Date,
+		ExtraLines:     entry.ExtraLines,
+		RawDate:        entry.RawDate,
+		raw:            entry.raw,
+		rawSeparator:   entry.rawSeparator,
+		rawTrailer:     entry.rawTrailer,
+	}
+	for _, change := range entry.Changes {
+		if !filter.MatchChange(entry, change) {
+			continue
+		}
+		matchedChange := Change{Summary: change.Summary, LeadingLines: change.LeadingLines, ExtraLines: change.ExtraLines}
+		for _, detail := range change.Details {
+			if matchDetail(filter, entry, change, detail) {
+				matchedChange.Details = append(matchedChange.Details, detail)
+			}
+		}
+		matchedEntry.Changes = append(matchedEntry.Changes, matchedChange)
+	}
+	return matchedEntry, len(matchedEntry.Changes) > 0
+}
+
+// FilterEntries returns the entries with changes selected by filter.
+func FilterEntries(entries []Entry, filter Filter) []Entry {
+	var matchedEntries []Entry
+	for _, entry := range entries {
+		if matchedEntry, ok := FilterEntry(entry, filter); ok {
+			matchedEntries = append(matchedEntries, matchedEntry)
+		}
+	}
+	return matchedEntries
+}
+
+// matchDetail returns whether detail is shown by filter, which is always
+// true if filter is not a DetailMatcher.
+func matchDetail(filter Filter, entry Entry, change Change, detail Detail) bool {
+	if m, ok := filter.(DetailMatcher); ok {
+		return m.MatchDetail(entry, change, detail)
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package changelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Formatter writes entries in an output format.
+type Formatter interface {
+	// WriteEntry writes e to w. It is called for each entry in order.
+	WriteEntry(w io.Writer, e Entry) error
+}
+
+// FormatterFunc is an adapter to use a function as a Formatter.
+type FormatterFunc func(w io.Writer, e Entry) error
+
+// WriteEntry calls f(w, e).
+func (f FormatterFunc) WriteEntry(w io.Writer, e Entry) error {
+	return f(w, e)
+}
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]Formatter{
+		"text":     FormatterFunc(writeText),
+		"ndjson":   FormatterFunc(writeJSON),
+		"jsonl":    FormatterFunc(writeJSON),
+		"markdown": FormatterFunc(writeMarkdown),
+	}
+)
+
+// RegisterFormatter makes f available by name with LookupFormatter,
+// replacing the formatter already registered by the name if any.
+func RegisterFormatter(name string, f Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	formatters[name] = f
+}
+
+// LookupFormatter returns the formatter registered by name.
+func LookupFormatter(name string) (Formatter, bool) {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	f, ok := formatters[name]
+	return f, ok
+}
+
+// FormatterNames returns the names of the registered formatters in
+// sorted order.
+func FormatterNames() []string {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeText writes e in the changelog format followed by a blank line.
+func writeText(w io.Writer, e Entry) error {
+	_, err := io.WriteString(w, e.String()+"\n\n")
+	return err
+}
+
+// writeJSON writes e as a JSON object on its own line, which is the
+// "ndjson" format, also available as "jsonl". There is no "json" format
+// since a JSON array cannot be written one entry at a time without state.
+func writeJSON(w io.Writer, e Entry) error {
+	return json.NewEncoder(w).Encode(e)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", "&lt;", ">", "&gt;", "#", `\#`,
+)
+
+// EscapeMarkdown escapes characters which have special meanings in
+// Markdown inline text, as the "markdown" formatter does.
+func EscapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// writeMarkdown writes e as a section with the heading line and the date
+// of e as the title and the changes as a nested list.
+func writeMarkdown(w io.Writer, e Entry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s (%s) %s; %s — %s\n\n", markdownEscaper.Replace(e.Package),
+		markdownEscaper.Replace(e.Version), markdownEscaper.Replace(e.Distributions),
+		markdownEscaper.Replace(e.Metadata), markdownEscaper.Replace(e.DateString()))
+	for _, change := range e.Changes {
+		fmt.Fprintf(&b, "- %s\n", markdownEscaper.Replace(change.Summary))
+		for _, detail := range change.Details {
+			for i, line := range detail.Lines {
+				prefix := "    "
+				if i == 0 {
+					prefix = "  - "
+				}
+				fmt.Fprintf(&b, "%s%s\n", prefix, markdownEscaper.Replace(line))
+			}
+		}
+	}
+	if len(e.Changes) > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "*%s &lt;%s&gt;, %s*\n\n", markdownEscaper.Replace(e.MaintainerName),
+		markdownEscaper.Replace(e.EmailAddress), e.DateString())
+	_, err := io.WriteString(w, b.String())
+	return err
+}
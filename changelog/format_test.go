@@ -0,0 +1,37 @@
+package changelog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarkdownHeadingHasDate(t *testing.T) {
+	f, ok := LookupFormatter("markdown")
+	if !ok {
+		t.Fatal("no markdown formatter")
+	}
+	for _, test := range []struct {
+		entry Entry
+		want  string
+	}{
+		{
+			Entry{Package: "linux", Version: "6.8.0-45.45", Distributions: "noble", Metadata: "urgency=medium",
+				Date: time.Date(2024, 8, 30, 14, 4, 45, 0, time.FixedZone("", 2*60*60))},
+			"## linux (6.8.0-45.45) noble; urgency=medium — Fri, 30 Aug 2024 14:04:45 +0200\n",
+		},
+		{
+			Entry{Package: "foo", Version: "1.0-1", Distributions: "unstable", Metadata: "urgency=low",
+				RawDate: "someday in 1999 #1"},
+			"## foo (1.0-1) unstable; urgency=low — someday in 1999 \\#1\n",
+		},
+	} {
+		var b strings.Builder
+		if err := f.WriteEntry(&b, test.entry); err != nil {
+			t.Fatal(err)
+		}
+		if got, _, _ := strings.Cut(b.String(), "\n"); got+"\n" != test.want {
+			t.Errorf("markdown heading = %q, want %q", got+"\n", test.want)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package changelog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type parseState int
+
+const (
+	parseStateInitial parseState = iota
+	parseStateInEntry
+	parseStateInChange
+	parseStateInDetail
+)
+
+// ParseFile parses the changelog file.
+func ParseFile(filename string) ([]Entry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries, err := Parse(bufio.NewReader(file))
+	if perr, ok := err.(*ParseError); ok {
+		perr.Filename = filename
+	}
+	return entries, err
+}
+
+// Parse parses a changelog and returns its entries.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	err := ParseEach(r, func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ParseEach parses a changelog and calls fn for each entry without
+// keeping the entries parsed so far, so that large changelogs can be
+// processed one entry at a time. Parsing stops at the first error
+// returned by fn, which is returned by ParseEach unless it is
+// ErrStopParsing.
+func ParseEach(r io.Reader, fn func(Entry) error) error {
+	if err := ParseFunc(r, fn, nil); err != nil && err != ErrStopParsing {
+		return err
+	}
+	return nil
+}
+
+// ErrStopParsing can be returned from the callback of ParseEach or
+// ParseFunc to stop parsing the rest of the input.
+var ErrStopParsing = errors.New("stop parsing")
+
+// ParseFunc parses a changelog and calls fn for each entry
+// as soon as its maintainer line is read, in the lenient mode of Parser
+// with warn as Warn.
+func ParseFunc(r io.Reader, fn func(Entry) error, warn func(lineNo int, message string)) error {
+	return (&Parser{Warn: warn}).Parse(r, fn)
+}
+
+// Parser parses changelogs with options.
+type Parser struct {
+	// Strict makes lines which do not fit the grammar of deb-changelog(5)
+	// stop parsing with a *ParseError of UnrecognizedLine. Otherwise
+	// unrecognized lines like "  [ John Doe ]" are kept in LeadingLines of
+	// the following change, in ExtraLines of the last change if they are
+	// after it, or in ExtraLines of the entry before its first change, and
+	// comment lines between entries are skipped.
+	Strict bool
+	// Warn is called for each unrecognized line if it is not nil.
+	Warn func(lineNo int, message string)
+}
+
+// Parse parses a changelog and calls fn for each entry when the heading
+// line of the next entry or the end of the input is read, so that the
+// text after the last entry is kept in its RawTrailer. A malformed
+// heading or maintainer line stops parsing with a *ParseError after fn
+// is called for the entries before it.
+func (p *Parser) Parse(r io.Reader, fn func(Entry) error) error {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	var entry *Entry
+	var change *Change
+	var detail *Detail
+	state := parseStateInitial
+	var raw strings.Builder
+	separator := ""
+	lineNo := 0
+	// pending is the last entry whose maintainer line is read, which is
+	// passed to fn when the next heading line or the end is read.
+	var pending *Entry
+	flush := func() error {
+		if pending == nil {
+			return nil
+		}
+		e := pending
+		pending = nil
+		return fn(*e)
+	}
+
+	// The indentation of the bullet of changes and the column where the
+	// text of the current detail starts, which are detected from the lines
+	// since real-world changelogs use "  * ", "   * " or "  - " for changes
+	// and various indentation for details.
+	changeIndent := 0
+	detailColumn := 0
+
+	// unrecognized are the unrecognized lines after a change, which are
+	// kept with the following change, or with the current change if it is
+	// continued or the entry ends.
+	var unrecognized []string
+	keepUnrecognized := func() {
+		if len(unrecognized) > 0 {
+			change.ExtraLines = append(change.ExtraLines, unrecognized...)
+			unrecognized = nil
+		}
+	}
+
+	processChangeLine := func(indent int, text string) {
+		entry.Changes = append(entry.Changes, Change{
+			Summary:      text,
+			LeadingLines: unrecognized,
+		})
+		unrecognized = nil
+		change = &entry.Changes[len(entry.Changes)-1]
+		changeIndent = indent
+		state = parseStateInChange
+	}
+
+	processDetailHeadLine := func(line, text string) {
+		change.Details = append(change.Details, Detail{
+			Lines: []string{text},
+		})
+		detail = &change.Details[len(change.Details)-1]
+		detailColumn = len(line) - len(text)
+		state = parseStateInDetail
+	}
+
+	processDetailTailLine := func(line string) {
+		detail.Lines = append(detail.Lines, line[detailColumn:])
+	}
+
+	processMaintainerLine := func(line string) error {
+		if err := ParseMaintainerLine(entry, line); err != nil {
+			// An entry with a date which cannot be parsed is kept with
+			// RawDate unless in the strict mode.
+			perr, ok := err.(*ParseError)
+			if p.Strict || !ok || perr.Kind != BadDate {
+				return atLine(err, lineNo)
+			}
+			if p.Warn != nil {
+				p.Warn(lineNo, "unrecognized date: "+entry.RawDate)
+			}
+		}
+		state = parseStateInitial
+		entry.raw = raw.String()
+		raw.Reset()
+		pending = entry
+		return nil
+	}
+
+	// keepLine keeps an unrecognized line in an entry, or skips it
+	// between entries.
+	keepLine := func(line string) error {
+		if p.Strict {
+			return &ParseError{Line: lineNo, Text: line, Kind: UnrecognizedLine}
+		}
+		switch state {
+		case parseStateInitial:
+			if p.Warn != nil {
+				p.Warn(lineNo, "unrecognized line skipped: "+line)
+			}
+			return nil
+		case parseStateInEntry:
+			entry.ExtraLines = append(entry.ExtraLines, line)
+		default:
+			unrecognized = append(unrecognized, line)
+		}
+		if p.Warn != nil {
+			p.Warn(lineNo, "unrecognized line: "+line)
+		}
+		return nil
+	}
+
+	for {
+		// The last line may not end with a newline.
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			if line == "" {
+				break
+			}
+		} else if err != nil {
+			return err
+		}
+		lineNo++
+		if state == parseStateInitial {
+			separator += raw.String()
+			raw.Reset()
+		}
+		raw.WriteString(line)
+		line = strings.TrimRight(line, "\n")
+		if len(line) == 0 {
+			continue
+		}
+
+		switch state {
+		case parseStateInitial:
+			if line[0] == '#' {
+				// Comments like "# Older entries have been removed from
+				// this changelog." added by dh_installchangelogs.
+				if err := keepLine(line); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			var err error
+			entry, err = ParseEntryLine(line)
+			if err != nil {
+				return atLine(err, lineNo)
+			}
+			entry.rawSeparator = separator
+			separator = ""
+			state = parseStateInEntry
+		case parseStateInEntry, parseStateInChange, parseStateInDetail:
+			indent, text, bullet := cutBullet(line)
+			switch {
+			case strings.HasPrefix(line, MaintainerLinePrefix):
+				if state != parseStateInEntry {
+					keepUnrecognized()
+				}
+				if err := processMaintainerLine(line); err != nil {
+					return err
+				}
+			case bullet && (state == parseStateInEntry || indent <= changeIndent):
+				processChangeLine(indent, text)
+			case state == parseStateInDetail && indent >= detailColumn:
+				// A wrapped or nested line of the detail.
+				keepUnrecognized()
+				processDetailTailLine(line)
+			case bullet:
+				keepUnrecognized()
+				processDetailHeadLine(line, text)
+			case state == parseStateInChange && indent > changeIndent:
+				// A summary wrapped onto the following lines.
+				keepUnrecognized()
+				change.Summary += " " + strings.TrimLeft(line, " ")
+			default:
+				if err := keepLine(line); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if pending != nil {
+		// Blank lines, comments and an incomplete entry after the last
+		// entry.
+		if state == parseStateInitial {
+			pending.rawTrailer = separator + raw.String()
+		} else {
+			pending.rawTrailer = entry.rawSeparator + raw.String()
+		}
+	}
+	return flush()
+}
+
+// cutBullet returns the number of spaces indenting line and the text after
+// the bullet if line is an indented item bulleted with "*", "-" or "+".
+func cutBullet(line string) (indent int, text string, ok bool) {
+	body := strings.TrimLeft(line, " ")
+	indent = len(line) - len(body)
+	if indent == 0 || len(body) < 2 || strings.IndexByte("*-+", body[0]) == -1 || body[1] != ' ' {
+		return indent, "", false
+	}
+	return indent, strings.TrimLeft(body[2:], " "), true
+}
+
+// atLine sets lineNo to err if it is a *ParseError.
+func atLine(err error, lineNo int) error {
+	if perr, ok := err.(*ParseError); ok {
+		perr.Line = lineNo
+	}
+	return err
+}
+
+// ParseEntryLine parses an entry heading line in the form of
+// "package (version) distributions; metadata". It returns a *ParseError
+// if line is malformed.
+func ParseEntryLine(line string) (*Entry, error) {
+	i := strings.IndexByte(line, ' ')
+	if i <= 0 {
+		return nil, &ParseError{Text: line, Kind: BadHeader}
+	}
+	pkg := line[:i]
+	rest, ok := cutSpaces(line[i:])
+	if !ok {
+		return nil, &ParseError{Text: line, Kind: BadHeader}
+	}
+	rest, ok = strings.CutPrefix(rest, "(")
+	if !ok {
+		return nil, &ParseError{Text: line, Kind: BadHeader}
+	}
+	version, rest, ok := strings.Cut(rest, ")")
+	if !ok || version == "" {
+		return nil, &ParseError{Text: line, Kind: BadHeader}
+	}
+	rest, ok = cutSpaces(rest)
+	if !ok {
+		return nil, &ParseError{Text: line, Kind: BadHeader}
+	}
+	distributions, rest, ok := strings.Cut(rest, ";")
+	if !ok || distributions == "" {
+		return nil, &ParseError{Text: line, Kind: BadHeader}
+	}
+	metadata, ok := cutSpaces(rest)
+	if !ok {
+		return nil, &ParseError{Text: line, Kind: BadHeader}
+	}
+	entry := &Entry{
+		Package:       pkg,
+		Version:       version,
+		Distributions: distributions,
+	}
+	entry.SetMetadata(metadata)
+	return entry, nil
+}
+
+// ParseMaintainerLine parses an entry trailer line in the form of
+// " -- maintainer name <email address>  date" and sets the fields to e.
+// It returns a *ParseError if line is malformed.
+func ParseMaintainerLine(e *Entry, line string) error {
+	rest, ok := strings.CutPrefix(line, MaintainerLinePrefix)
+	if !ok {
+		return &ParseError{Text: line, Kind: BadMaintainerLine}
+	}
+	name, rest, ok := strings.Cut(rest, "<")
+	if !ok || len(name) < 2 || name[len(name)-1] != ' ' {
+		return &ParseError{Text: line, Kind: BadMaintainerLine}
+	}
+	name = name[:len(name)-1]
+	email, rest, ok := strings.Cut(rest, ">")
+	if !ok || email == "" {
+		return &ParseError{Text: line, Kind: BadMaintainerLine}
+	}
+	date, ok := cutSpaces(rest)
+	if !ok {
+		return &ParseError{Text: line, Kind: BadMaintainerLine}
+	}
+	e.MaintainerName = name
+	e.EmailAddress = email
+	d, err := parseDate(date)
+	if err != nil {
+		e.Date = time.Time{}
+		e.RawDate = date
+		return &ParseError{Text: line, Kind: BadDate, Err: err}
+	}
+	e.Date = d
+	e.RawDate = ""
+	return nil
+}
+
+// legacyDateLayouts are layouts of dates in old entries tried after
+// DateFormat, without the day of the week, with two-digit years, without
+// seconds, or with timezone abbreviations. Days may have one digit.
+var legacyDateLayouts = func() []string {
+	var layouts []string
+	for _, weekday := range []string{"Mon, ", "Mon ", ""} {
+		for _, year := range []string{"2006", "06"} {
+			for _, clock := range []string{"15:04:05", "15:04"} {
+				for _, zone := range []string{"-0700", "MST"} {
+					layouts = append(layouts, weekday+"2 Jan "+year+" "+clock+" "+zone)
+				}
+			}
+		}
+	}
+	return layouts
+}()
+
+// timezoneOffsets are offsets of timezone abbreviations seen in old
+// entries, which time.Parse does not know unless they are of the local
+// timezone.
+var timezoneOffsets = map[string]string{
+	"UT": "+0000", "GMT": "+0000", "UTC": "+0000",
+	"EST": "-0500", "EDT": "-0400", "CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600", "PST": "-0800", "PDT": "-0700",
+	"WET": "+0000", "WEST": "+0100", "BST": "+0100",
+	"CET": "+0100", "CEST": "+0200", "MET": "+0100", "MEST": "+0200",
+	"EET": "+0200", "EEST": "+0300", "JST": "+0900",
+}
+
+// parseDate parses date in DateFormat, or in one of legacyDateLayouts
+// after squeezing spaces and removing a comment like "(CEST)" after the
+// offset.
+func parseDate(date string) (time.Time, error) {
+	d, err := time.Parse(DateFormat, date)
+	if err == nil {
+		return d, nil
+	}
+	fields := strings.Fields(date)
+	if n := len(fields); n > 1 && strings.HasPrefix(fields[n-1], "(") && strings.HasSuffix(fields[n-1], ")") {
+		fields = fields[:n-1]
+	}
+	if n := len(fields); n > 0 {
+		if offset, ok := timezoneOffsets[strings.ToUpper(fields[n-1])]; ok {
+			fields[n-1] = offset
+		}
+	}
+	normalized := strings.Join(fields, " ")
+	for _, layout := range legacyDateLayouts {
+		if d, err := time.Parse(layout, normalized); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// cutSpaces removes one or more leading spaces from s.
+// It returns false if s does not start with a space.
+func cutSpaces(s string) (string, bool) {
+	t := strings.TrimLeft(s, " ")
+	return t, len(t) < len(s)
+}
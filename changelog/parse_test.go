@@ -0,0 +1,268 @@
+package changelog
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// The regular expressions used before ParseEntryLine and
+// ParseMaintainerLine were written by hand, which they must agree with.
+var (
+	entryLineRegexp      = regexp.MustCompile(`^([^ ]+) +\(([^)]+)\) +([^;]+); +(.*)`)
+	maintainerLineRegexp = regexp.MustCompile(`^` + MaintainerLinePrefix + `([^<]+) +<([^>]+)> +(.*)`)
+)
+
+var entryLineTests = []string{
+	"linux (6.8.0-45.45) noble; urgency=medium",
+	"linux  (6.8.0-45.45)  noble proposed;  urgency=medium, binary-only=yes",
+	"linux (6.8.0-45.45) noble; ",
+	"linux (6.8.0-45.45) noble;",
+	"linux (6.8.0-45.45) noble urgency=medium",
+	"linux (6.8.0-45.45)noble; urgency=medium",
+	"linux(6.8.0-45.45) noble; urgency=medium",
+	"linux 6.8.0-45.45 noble; urgency=medium",
+	"linux () noble; urgency=medium",
+	"linux (6.8.0 noble; urgency=medium",
+	"linux (6.8.0) ; urgency=medium",
+	" linux (6.8.0) noble; urgency=medium",
+	"linux",
+	"",
+	"binutils (2.7-3):",
+}
+
+func TestParseEntryLineMatchesRegexp(t *testing.T) {
+	for _, line := range entryLineTests {
+		m := entryLineRegexp.FindStringSubmatch(line)
+		e, err := ParseEntryLine(line)
+		if (m != nil) != (err == nil) {
+			t.Errorf("ParseEntryLine(%q): err=%v, regexp match=%v", line, err, m != nil)
+			continue
+		}
+		if m == nil {
+			if perr, ok := err.(*ParseError); !ok || perr.Kind != BadHeader {
+				t.Errorf("ParseEntryLine(%q): err=%v, want BadHeader", line, err)
+			}
+			continue
+		}
+		got := []string{e.Package, e.Version, e.Distributions, e.Metadata}
+		if strings.Join(got, "\x00") != strings.Join(m[1:], "\x00") {
+			t.Errorf("ParseEntryLine(%q) = %q, want %q", line, got, m[1:])
+		}
+	}
+}
+
+var maintainerLineTests = []string{
+	" -- John Doe <john@example.com>  Mon, 09 Sep 2024 10:00:00 +0200",
+	" -- John Doe <john@example.com> Mon, 09 Sep 2024 10:00:00 +0200",
+	" -- John Doe  <john@example.com>  Mon, 09 Sep 2024 10:00:00 +0200",
+	" -- John Doe<john@example.com>  Mon, 09 Sep 2024 10:00:00 +0200",
+	" -- John Doe <john@example.com>Mon, 09 Sep 2024 10:00:00 +0200",
+	" -- John Doe <john@example.com>",
+	" -- John Doe <>  Mon, 09 Sep 2024 10:00:00 +0200",
+	" -- John Doe <john@example.com  Mon, 09 Sep 2024 10:00:00 +0200",
+	" --  <john@example.com>  Mon, 09 Sep 2024 10:00:00 +0200",
+	" -- <john@example.com>  Mon, 09 Sep 2024 10:00:00 +0200",
+	"-- John Doe <john@example.com>  Mon, 09 Sep 2024 10:00:00 +0200",
+	" -- John Doe <john@example.com>  someday in 1999",
+	" -- ",
+}
+
+func TestParseMaintainerLineMatchesRegexp(t *testing.T) {
+	for _, line := range maintainerLineTests {
+		m := maintainerLineRegexp.FindStringSubmatch(line)
+		var e Entry
+		err := ParseMaintainerLine(&e, line)
+		// The regular expression only checks the syntax and leaves the
+		// date to time.Parse.
+		perr, _ := err.(*ParseError)
+		accepted := err == nil || perr != nil && perr.Kind == BadDate
+		if (m != nil) != accepted {
+			t.Errorf("ParseMaintainerLine(%q): err=%v, regexp match=%v", line, err, m != nil)
+			continue
+		}
+		if m == nil {
+			if perr == nil || perr.Kind != BadMaintainerLine {
+				t.Errorf("ParseMaintainerLine(%q): err=%v, want BadMaintainerLine", line, err)
+			}
+			continue
+		}
+		got := []string{e.MaintainerName, e.EmailAddress, e.DateString()}
+		if err == nil {
+			got[2] = m[3]
+		}
+		if strings.Join(got, "\x00") != strings.Join(m[1:], "\x00") {
+			t.Errorf("ParseMaintainerLine(%q) = %q, want %q", line, got, m[1:])
+		}
+	}
+}
+
+const (
+	benchEntryLine      = "linux (6.8.0-45.45) noble; urgency=medium"
+	benchMaintainerLine = " -- Stefan Bader <stefan.bader@canonical.com>  Fri, 30 Aug 2024 14:04:45 +0200"
+)
+
+func BenchmarkParseEntryLine(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseEntryLine(benchEntryLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseEntryLineRegexp(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		m := entryLineRegexp.FindStringSubmatch(benchEntryLine)
+		if m == nil {
+			b.Fatal("no match")
+		}
+		e := &Entry{Package: m[1], Version: m[2], Distributions: m[3]}
+		e.SetMetadata(m[4])
+	}
+}
+
+func BenchmarkParseMaintainerLine(b *testing.B) {
+	var e Entry
+	for i := 0; i < b.N; i++ {
+		if err := ParseMaintainerLine(&e, benchMaintainerLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseMaintainerLineRegexp(b *testing.B) {
+	var e Entry
+	for i := 0; i < b.N; i++ {
+		m := maintainerLineRegexp.FindStringSubmatch(benchMaintainerLine)
+		if m == nil {
+			b.Fatal("no match")
+		}
+		e.MaintainerName = m[1]
+		e.EmailAddress = m[2]
+		d, err := parseDate(m[3])
+		if err != nil {
+			b.Fatal(err)
+		}
+		e.Date = d
+	}
+}
+
+// benchChangelog returns a changelog of n entries like those of the
+// Ubuntu kernel.
+func benchChangelog(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString(benchEntryLine + "\n\n")
+		for j := 0; j < 20; j++ {
+			b.WriteString("  * Noble update: v6.8.12 upstream stable release (LP: #2073603)\n")
+			b.WriteString("    - mm: fix a race in the page cache\n")
+			b.WriteString("      (CVE-2024-12345)\n")
+		}
+		b.WriteString("\n" + benchMaintainerLine + "\n\n")
+	}
+	return b.String()
+}
+
+// benchmarkParse parses a changelog with parseEntryLine and
+// parseMaintainerLine called for the heading and the trailer lines, to
+// compare the time to parse whole changelogs with each of them.
+func benchmarkParse(b *testing.B, parseEntryLine func(string) bool, parseMaintainerLine func(string) bool) {
+	lines := strings.Split(benchChangelog(100), "\n")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			switch {
+			case strings.HasPrefix(line, MaintainerLinePrefix):
+				if !parseMaintainerLine(line) {
+					b.Fatal("bad maintainer line")
+				}
+			case line != "" && line[0] != ' ':
+				if !parseEntryLine(line) {
+					b.Fatal("bad heading")
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkParseHeadingsAndTrailers(b *testing.B) {
+	var e Entry
+	benchmarkParse(b, func(line string) bool {
+		_, err := ParseEntryLine(line)
+		return err == nil
+	}, func(line string) bool {
+		return ParseMaintainerLine(&e, line) == nil
+	})
+}
+
+func BenchmarkParseHeadingsAndTrailersRegexp(b *testing.B) {
+	benchmarkParse(b, func(line string) bool {
+		return entryLineRegexp.FindStringSubmatch(line) != nil
+	}, func(line string) bool {
+		m := maintainerLineRegexp.FindStringSubmatch(line)
+		if m == nil {
+			return false
+		}
+		_, err := parseDate(m[3])
+		return err == nil
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	text := benchChangelog(100)
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ParseEach(strings.NewReader(text), func(Entry) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseKeepsUnrecognizedLinesWithFollowingChange(t *testing.T) {
+	text := `linux (6.8.0-45.45) noble; urgency=medium
+
+  [ Ubuntu: 6.8.0-45.45 ]
+
+  * noble/linux: 6.8.0-45.45 -proposed tracker (LP: #2078100)
+
+  [ John Doe ]
+
+  * Fix the frobnicator
+    - mm: detail
+  * Another change
+
+  [ Jane Doe ]
+
+ -- Stefan Bader <stefan.bader@canonical.com>  Fri, 30 Aug 2024 14:04:45 +0200
+`
+	entries, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := entries[0]
+	if got, want := strings.Join(e.ExtraLines, "|"), "  [ Ubuntu: 6.8.0-45.45 ]"; got != want {
+		t.Errorf("entry ExtraLines = %q, want %q", got, want)
+	}
+	for i, want := range []struct{ leading, extra string }{
+		{"", ""},
+		{"  [ John Doe ]", ""},
+		{"", "  [ Jane Doe ]"},
+	} {
+		c := e.Changes[i]
+		if got := strings.Join(c.LeadingLines, "|"); got != want.leading {
+			t.Errorf("change %d LeadingLines = %q, want %q", i, got, want.leading)
+		}
+		if got := strings.Join(c.ExtraLines, "|"); got != want.extra {
+			t.Errorf("change %d ExtraLines = %q, want %q", i, got, want.extra)
+		}
+	}
+
+	var b strings.Builder
+	if _, err := e.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if want := "  [ John Doe ]\n  * Fix the frobnicator\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("WriteTo() =\n%s\nwant to contain:\n%s", b.String(), want)
+	}
+}
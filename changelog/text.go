@@ -0,0 +1,107 @@
+package changelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// appendText appends the entry in the debian/changelog format with blank
+// lines around changes and two spaces between the email address and the
+// date in the trailer line.
+func (e *Entry) appendText(b []byte) []byte {
+	b = fmt.Appendf(b, "%s (%s) %s; %s\n\n", e.Package, e.Version, e.Distributions, e.Metadata)
+	for _, line := range e.ExtraLines {
+		b = append(b, line+"\n"...)
+	}
+	for _, change := range e.Changes {
+		for _, line := range change.LeadingLines {
+			b = append(b, line+"\n"...)
+		}
+		b = append(b, ChangePrefix+change.Summary+"\n"...)
+		for _, detail := range change.Details {
+			for i, line := range detail.Lines {
+				prefix := DetailHeadPrefix
+				if i > 0 {
+					prefix = DetailTailPrefix
+				}
+				b = append(b, prefix+line+"\n"...)
+			}
+		}
+		for _, line := range change.ExtraLines {
+			b = append(b, line+"\n"...)
+		}
+	}
+	if len(e.Changes) > 0 {
+		b = append(b, '\n')
+	}
+	return fmt.Appendf(b, MaintainerLinePrefix+"%s <%s>  %s\n", e.MaintainerName, e.EmailAddress, e.DateString())
+}
+
+// WriteTo writes the entry in the debian/changelog format, which is
+// parsed to the same entry again.
+func (e *Entry) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(e.appendText(nil))
+	return int64(n), err
+}
+
+// MarshalText returns the entry in the debian/changelog format like
+// WriteTo.
+func (e *Entry) MarshalText() ([]byte, error) {
+	return e.appendText(nil), nil
+}
+
+// UnmarshalText parses text which has exactly one entry in the
+// debian/changelog format.
+func (e *Entry) UnmarshalText(text []byte) error {
+	var entries []Entry
+	if err := ParseEach(bytes.NewReader(text), func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(entries) != 1 {
+		return fmt.Errorf("changelog: text must have exactly one entry, got %d", len(entries))
+	}
+	*e = entries[0]
+	return nil
+}
+
+// entryFields has the fields of Entry without its methods, so that JSON
+// and XML encode entries as objects rather than with MarshalText.
+type entryFields Entry
+
+// MarshalJSON encodes the entry as a JSON object of its fields.
+func (e *Entry) MarshalJSON() ([]byte, error) {
+	return json.Marshal((*entryFields)(e))
+}
+
+// UnmarshalJSON decodes a JSON object of the fields of an entry.
+// MetadataFields and Urgency are set from Metadata if they are missing.
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*entryFields)(e)); err != nil {
+		return err
+	}
+	if e.MetadataFields == nil && e.Metadata != "" {
+		e.SetMetadata(e.Metadata)
+	}
+	return nil
+}
+
+// MarshalXML encodes the entry as an element of its fields.
+func (e *Entry) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	return enc.EncodeElement((*entryFields)(e), start)
+}
+
+// UnmarshalXML decodes an element of the fields of an entry, with
+// MetadataFields and Urgency set from Metadata.
+func (e *Entry) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	if err := dec.DecodeElement((*entryFields)(e), &start); err != nil {
+		return err
+	}
+	e.SetMetadata(e.Metadata)
+	return nil
+}
@@ -0,0 +1,160 @@
+package changelog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidateVersion checks v is in the form of [epoch:]upstream_version[-debian_revision].
+// https://www.debian.org/doc/debian-policy/ch-controlfields.html#version
+func ValidateVersion(v string) error {
+	if v == "" {
+		return fmt.Errorf("empty version")
+	}
+	rest := v
+	if epoch, upstream, ok := strings.Cut(rest, ":"); ok {
+		if epoch == "" || strings.Trim(epoch, "0123456789") != "" {
+			return fmt.Errorf("epoch must be an unsigned integer: %s", v)
+		}
+		rest = upstream
+	}
+	upstream := rest
+	if i := strings.LastIndexByte(rest, '-'); i != -1 {
+		upstream = rest[:i]
+		revision := rest[i+1:]
+		if revision == "" {
+			return fmt.Errorf("empty debian revision: %s", v)
+		}
+		if c, ok := findInvalidVersionChar(revision, "+.~"); ok {
+			return fmt.Errorf("invalid character %q in debian revision: %s", c, v)
+		}
+		if c, ok := findInvalidVersionChar(upstream, "+.~-"); ok {
+			return fmt.Errorf("invalid character %q in upstream version: %s", c, v)
+		}
+	} else if c, ok := findInvalidVersionChar(upstream, "+.~"); ok {
+		return fmt.Errorf("invalid character %q in upstream version: %s", c, v)
+	}
+	if upstream == "" || !isDigit(upstream[0]) {
+		return fmt.Errorf("upstream version must start with a digit: %s", v)
+	}
+	return nil
+}
+
+// findInvalidVersionChar returns the first character in s which is neither
+// an alphanumeric nor one of allowed.
+func findInvalidVersionChar(s, allowed string) (rune, bool) {
+	for _, c := range s {
+		if c < 0x80 && (isDigit(byte(c)) || isAlpha(byte(c))) {
+			continue
+		}
+		if !strings.ContainsRune(allowed, c) {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// CompareVersions compares Debian package versions a and b with the same
+// algorithm as dpkg. It returns a negative number if a < b, zero if a == b,
+// and a positive number if a > b.
+//
+// Epochs are compared as numbers first, then the upstream versions and
+// the debian revisions. They are compared by alternating non-digit parts,
+// where letters sort before non-letters and '~' sorts before anything
+// even the end, and digit parts compared as numbers. So "1:1.0" > "2.0",
+// "6.8.0-45.45" > "6.8.0-9.9" and "1.0~rc1" < "1.0".
+func CompareVersions(a, b string) int {
+	aEpoch, aUpstream, aRevision := splitVersion(a)
+	bEpoch, bUpstream, bRevision := splitVersion(b)
+	if aEpoch != bEpoch {
+		if aEpoch < bEpoch {
+			return -1
+		}
+		return 1
+	}
+	if c := compareVersionPart(aUpstream, bUpstream); c != 0 {
+		return c
+	}
+	return compareVersionPart(aRevision, bRevision)
+}
+
+func splitVersion(v string) (epoch int, upstream, revision string) {
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		epoch, _ = strconv.Atoi(e)
+		v = rest
+	}
+	if i := strings.LastIndexByte(v, '-'); i != -1 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+// compareVersionPart is a port of verrevcmp in dpkg lib/dpkg/version.c.
+func compareVersionPart(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			ac, bc := versionCharOrder(a), versionCharOrder(b)
+			if ac != bc {
+				return ac - bc
+			}
+			a, b = a[1:], b[1:]
+		}
+		a = strings.TrimLeft(a, "0")
+		b = strings.TrimLeft(b, "0")
+		firstDiff := 0
+		for a != "" && isDigit(a[0]) && b != "" && isDigit(b[0]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[0]) - int(b[0])
+			}
+			a, b = a[1:], b[1:]
+		}
+		if a != "" && isDigit(a[0]) {
+			return 1
+		}
+		if b != "" && isDigit(b[0]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
+
+// versionCharOrder returns the sort weight of the first character of s.
+// Letters sort before non-letters and '~' sorts before anything, even
+// the end of a part.
+func versionCharOrder(s string) int {
+	if s == "" {
+		return 0
+	}
+	c := s[0]
+	switch {
+	case isDigit(c):
+		return 0
+	case isAlpha(c):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+// SortEntries sorts entries in the order of changelogs, from the newest
+// version to the oldest. Entries of the same version keep their order.
+func SortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return CompareVersions(entries[i].Version, entries[j].Version) > 0
+	})
+}
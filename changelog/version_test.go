@@ -0,0 +1,96 @@
+package changelog
+
+import "testing"
+
+// The cases are from the tests of dpkg --compare-versions and the Debian
+// policy on the sorting of versions.
+var compareVersionsTests = []struct {
+	a, b string
+	want int
+}{
+	// Equal versions.
+	{"1.0", "1.0", 0},
+	{"1.0-1", "1.0-1", 0},
+	{"0:1.0", "1.0", 0},
+	{"1.0", "1.0-0", 0},
+	{"0:1.0-0", "1.0", 0},
+	{"1.00", "1.0", 0},
+
+	// Epochs are compared first.
+	{"1:0.1", "2.0", 1},
+	{"1:1.0", "2:0.1", -1},
+	{"10:1.0", "9:1.0", 1},
+
+	// Upstream versions.
+	{"1.0", "1.0.1", -1},
+	{"1.2", "1.10", -1},
+	{"1.0a", "1.0", 1},
+	{"1.0a", "1.0b", -1},
+	{"1.0.", "1.0+", 1},
+	{"1.0+dfsg", "1.0", 1},
+	{"1.0.", "1.0+", 1},
+	{"6.8.0-45.45", "6.8.0-9.9", 1},
+
+	// "~" sorts before anything, even the end of the version.
+	{"1.0~rc1", "1.0", -1},
+	{"1.0~", "1.0", -1},
+	{"1.0~~", "1.0~", -1},
+	{"1.0~~", "1.0~~a", -1},
+	{"1.0~~a", "1.0~", -1},
+	{"1.0~rc1", "1.0~rc2", -1},
+	{"1.0~beta", "1.0~alpha", 1},
+
+	// Debian revisions.
+	{"1.0-1", "1.0-2", -1},
+	{"1.0-1", "1.0-1+b1", -1},
+	{"1.0-1", "1.0-1.1", -1},
+	{"1.0-1~bpo1", "1.0-1", -1},
+	{"1.0-1ubuntu1", "1.0-1", 1},
+	{"1.0-1ubuntu0.1", "1.0-1ubuntu1", -1},
+	{"1.0-1+deb12u1", "1.0-1", 1},
+	{"1.0-10", "1.0-9", 1},
+	{"1.0-1-1", "1.0-1", 1},
+	{"1.0-1", "1.0.1-0", -1},
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, test := range compareVersionsTests {
+		if got := CompareVersions(test.a, test.b); sign(got) != test.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := CompareVersions(test.b, test.a); sign(got) != -test.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func TestSortEntries(t *testing.T) {
+	entries := []Entry{
+		{Version: "1.0-1", Package: "a"},
+		{Version: "1.0~rc1-1"},
+		{Version: "1:0.1-1"},
+		{Version: "1.0-1", Package: "b"},
+		{Version: "1.0-1ubuntu1"},
+	}
+	SortEntries(entries)
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Version+e.Package)
+	}
+	want := []string{"1:0.1-1", "1.0-1ubuntu1", "1.0-1a", "1.0-1b", "1.0~rc1-1"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("SortEntries() = %q, want %q", got, want)
+		}
+	}
+}
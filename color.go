@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+const (
+	colorReset      = "\x1b[0m"
+	colorHeading    = "\x1b[1;33m"
+	colorBullet     = "\x1b[32m"
+	colorMaintainer = "\x1b[36m"
+	colorDate       = "\x1b[35m"
+	colorHighlight  = "\x1b[1;31m"
+)
+
+// validateColorMode checks the value of the -color flag.
+func validateColorMode(mode string) error {
+	switch mode {
+	case "auto", "always", "never":
+		return nil
+	default:
+		return fmt.Errorf(`invalid -color %q, must be "auto", "always" or "never"`, mode)
+	}
+}
+
+// useColor returns whether to color output written to w in mode.
+// In the "auto" mode, output is colored only when w is a terminal and
+// the NO_COLOR environment variable is not set (https://no-color.org/).
+func useColor(mode string, w io.Writer) bool {
+	switch mode {
+	case "always":
+		return true
+	case "auto":
+		file, ok := w.(*os.File)
+		return ok && isTerminal(file) && os.Getenv("NO_COLOR") == ""
+	default:
+		return false
+	}
+}
+
+// coloredEntryString returns the same text as e.String() with ANSI colors for
+// the heading line, bullets, the maintainer and the date. Substrings of
+// summaries and detail lines matched with any of highlights are also
+// colored. suffix is added after the date.
+func coloredEntryString(e *Entry, suffix string, highlights []*regexp.Regexp) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s (%s) %s; %s%s\n", colorHeading, e.Package, e.Version, e.Distributions, e.Metadata, colorReset)
+	for _, line := range e.ExtraLines {
+		b.WriteString(line + "\n")
+	}
+	for _, change := range e.Changes {
+		for _, line := range change.LeadingLines {
+			b.WriteString(line + "\n")
+		}
+		fmt.Fprintf(&b, "  %s*%s %s\n", colorBullet, colorReset, highlight(change.Summary, highlights))
+		for _, detail := range change.Details {
+			for i, line := range detail.Lines {
+				line = highlight(line, highlights)
+				if i == 0 {
+					fmt.Fprintf(&b, "    %s-%s %s\n", colorBullet, colorReset, line)
+				} else {
+					fmt.Fprintf(&b, changelog.DetailTailPrefix+"%s\n", line)
+				}
+			}
+		}
+		for _, line := range change.ExtraLines {
+			b.WriteString(line + "\n")
+		}
+	}
+	fmt.Fprintf(&b, "%s%s <%s>%s %s%s%s%s", colorMaintainer, changelog.MaintainerLinePrefix+e.MaintainerName, e.EmailAddress, colorReset,
+		colorDate, e.DateString(), suffix, colorReset)
+	return b.String()
+}
+
+// highlight returns s with substrings matched with any of res colored.
+// Overlapping matches are colored together.
+func highlight(s string, res []*regexp.Regexp) string {
+	var ranges [][]int
+	for _, re := range res {
+		for _, loc := range re.FindAllStringIndex(s, -1) {
+			if loc[0] < loc[1] {
+				ranges = append(ranges, loc)
+			}
+		}
+	}
+	if len(ranges) == 0 {
+		return s
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+	var b strings.Builder
+	pos := 0
+	for i := 0; i < len(ranges); {
+		start, end := ranges[i][0], ranges[i][1]
+		for i++; i < len(ranges) && ranges[i][0] <= end; i++ {
+			if ranges[i][1] > end {
+				end = ranges[i][1]
+			}
+		}
+		b.WriteString(s[pos:start])
+		b.WriteString(colorHighlight + s[start:end] + colorReset)
+		pos = end
+	}
+	b.WriteString(s[pos:])
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const appName = "ubuntu-linux-changelog-filter"
+
+// config is read from a JSON file, by default
+// $XDG_CONFIG_HOME/ubuntu-linux-changelog-filter/config.json.
+type config struct {
+	// TemplatesDir is the directory of "name.tmpl" files which
+	// can be selected with "-format name".
+	TemplatesDir string `json:"templates_dir"`
+	// Sources maps names to external commands selectable with -source.
+	Sources   map[string]sourceConfig `json:"sources"`
+	Launchpad *launchpadConfig        `json:"launchpad"`
+	Mirror    *mirrorConfig           `json:"mirror"`
+	Lint      lintConfig              `json:"lint"`
+	// Maintainer is used by new-entry if the environment variables of
+	// the maintainer are not set.
+	Maintainer maintainerConfig `json:"maintainer"`
+}
+
+type maintainerConfig struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type lintConfig struct {
+	// Profile is the name of the lint profile used when -profile is not specified.
+	Profile  string                 `json:"profile"`
+	Profiles map[string]lintProfile `json:"profiles"`
+}
+
+// lintProfile enables, disables and tunes severities of lint rules.
+type lintProfile struct {
+	// Extends is the name of the profile this profile is based on.
+	Extends       string `json:"extends"`
+	MaxLineLength int    `json:"max_line_length"`
+	// Rules maps rule names to "off", "warning" or "error".
+	Rules map[string]string `json:"rules"`
+}
+
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appName, "config.json"), nil
+}
+
+// loadConfig reads the config file. If filename is empty, the default
+// config file is read if it exists.
+func loadConfig(filename string) (*config, error) {
+	explicit := filename != ""
+	if !explicit {
+		var err error
+		filename, err = defaultConfigPath()
+		if err != nil {
+			return &config{}, nil
+		}
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return &config{}, nil
+		}
+		return nil, err
+	}
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %s", filename, err)
+	}
+	return &c, nil
+}
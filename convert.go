@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+// changelogWriter writes entries in the debian/changelog format, which
+// can be parsed again unlike the compact text format.
+type changelogWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newChangelogWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &changelogWriter{w: w}
+}
+
+func (c *changelogWriter) WriteEntry(entry Entry) error {
+	if c.count > 0 {
+		if _, err := io.WriteString(c.w, "\n"); err != nil {
+			return err
+		}
+	}
+	c.count++
+	_, err := entry.WriteTo(c.w)
+	return err
+}
+
+func (c *changelogWriter) Close() error {
+	return nil
+}
+
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	filename := fs.String("file", "-", `input filename ("-" for stdin)`)
+	from := fs.String("from", "json", `input format, "json" (an array or one object per line) or "changelog"`)
+	to := fs.String("to", "changelog", fmt.Sprintf("output format, one of %s", strings.Join(outputFormatNames(), ", ")))
+	output := fs.String("output", "-", `output filename ("-" for stdout)`)
+	addErrorsFlag(fs)
+	fs.Parse(args)
+
+	target, err := parseOutputTarget(*to + "=" + *output)
+	if err != nil {
+		return err
+	}
+
+	r, err := openInput(*filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out, err := openOutputs([]outputTarget{target}, outputOptions{})
+	if err != nil {
+		return err
+	}
+	defer out.Abort()
+
+	switch *from {
+	case "json":
+		err = decodeJSONEntries(r, out.WriteEntry)
+	case "changelog":
+		err = changelog.ParseFunc(r, out.WriteEntry, nil)
+	default:
+		return fmt.Errorf(`unknown input format %q, must be "json" or "changelog"`, *from)
+	}
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// decodeJSONEntries reads entries in a JSON array or a stream of JSON
+// objects such as NDJSON, and calls fn for each of them.
+func decodeJSONEntries(r io.Reader, fn func(Entry) error) error {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	c, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if c == '[' {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		for dec.More() {
+			var entry Entry
+			if err := dec.Decode(&entry); err != nil {
+				return err
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		_, err := dec.Token()
+		return err
+	}
+	for {
+		var entry Entry
+		if err := dec.Decode(&entry); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// peekNonSpace skips white spaces and returns the next byte without
+// consuming it.
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
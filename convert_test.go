@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunConvert(t *testing.T) {
+	dir := t.TempDir()
+	filename := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	if err := os.WriteFile(filename("input.changelog"), []byte(runTestChangelog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Entries are converted to JSON and back, through a JSON array and
+	// NDJSON, and written as the changelog written from the original.
+	for _, args := range [][]string{
+		{"-from", "changelog", "-to", "changelog", "-file", filename("input.changelog"), "-output", filename("want.changelog")},
+		{"-from", "changelog", "-to", "json", "-file", filename("input.changelog"), "-output", filename("array.json")},
+		{"-from", "changelog", "-to", "ndjson", "-file", filename("input.changelog"), "-output", filename("lines.json")},
+		{"-file", filename("array.json"), "-output", filename("array.changelog")},
+		{"-from", "json", "-to", "changelog", "-file", filename("lines.json"), "-output", filename("lines.changelog")},
+	} {
+		if err := runConvert(args); err != nil {
+			t.Fatalf("runConvert(%q) = %v", args, err)
+		}
+	}
+	want, err := os.ReadFile(filename("want.changelog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(want) != string(runForTest(t, options{}, "changelog", runTestChangelog)) {
+		t.Errorf("convert -to changelog wrote\n%s\nwant the changelog output", want)
+	}
+	for _, name := range []string{"array.changelog", "lines.changelog"} {
+		got, err := os.ReadFile(filename(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("convert wrote %s\n%s\nwant\n%s", name, got, want)
+		}
+	}
+
+	err = runConvert([]string{"-from", "yaml", "-file", filename("input.changelog"), "-output", filename("yaml.changelog")})
+	if want := `unknown input format "yaml", must be "json" or "changelog"`; err == nil || err.Error() != want {
+		t.Errorf("runConvert(-from yaml) = %v, want %q", err, want)
+	}
+}
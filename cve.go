@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cveRegex matches a well-formed CVE ID.
+// https://cve.mitre.org/cve/identifiers/syntaxchange.html
+var cveRegex = regexp.MustCompile(`\bCVE-\d{4}-\d{4,}\b`)
+
+// usnRegex matches an Ubuntu Security Notice ID like "USN-6816-1".
+var usnRegex = regexp.MustCompile(`\bUSN-\d+-\d+\b`)
+
+// isSecurityEntry returns whether entry is uploaded to a -security
+// pocket, or its changes reference CVE IDs or USNs.
+func isSecurityEntry(entry *Entry) bool {
+	for _, dist := range strings.Fields(entry.Distributions) {
+		if strings.HasSuffix(dist, "-security") {
+			return true
+		}
+	}
+	for _, change := range entry.Changes {
+		if change.Matches(cveRegex) || change.Matches(usnRegex) {
+			return true
+		}
+	}
+	return false
+}
+
+// cveCandidateRegex matches strings which look like CVE IDs including
+// typos like "CVE-2024-123", "CVE2024-1234", "CVE-2024-1234a" or
+// "cve-2024-1234".
+var cveCandidateRegex = regexp.MustCompile(`(?i)\bCVE[-_ ]?\d+(?:[-_ ]?\d+)?[A-Za-z0-9]*\b`)
+
+// validateCVE checks id is in the form of CVE-YYYY-NNNN with a plausible
+// year. "CVE" must be in upper case, since cveRegex finding CVE IDs for
+// the outputs like cve-groups is case sensitive.
+func validateCVE(id string) error {
+	if cveRegex.FindString(id) != id {
+		if upper := strings.ToUpper(id); cveRegex.FindString(upper) == upper {
+			return fmt.Errorf("CVE ID must be in upper case: %s", id)
+		}
+		return fmt.Errorf("malformed CVE ID: %s", id)
+	}
+	year, _ := strconv.Atoi(id[len("CVE-") : len("CVE-")+4])
+	if year < 1999 || year > time.Now().Year()+1 {
+		return fmt.Errorf("implausible year in CVE ID: %s", id)
+	}
+	return nil
+}
+
+func (l *linter) checkCVEs(lineNo int, line string) {
+	for _, id := range cveCandidateRegex.FindAllString(line, -1) {
+		if err := validateCVE(id); err != nil {
+			l.report(lineNo, severityError, "cve", "%s", err)
+		}
+	}
+}
+
+// cveLookupURLs are URL formats to check existence of a CVE ID.
+var cveLookupURLs = map[string]string{
+	"ubuntu": "https://ubuntu.com/security/cves/%s.json",
+	"mitre":  "https://cveawg.mitre.org/api/cve/%s",
+}
+
+func runVerifyCVEs(args []string) error {
+	fs := flag.NewFlagSet("verify-cves", flag.ExitOnError)
+	filename := fs.String("file", "-", `changelog filename ("-" for stdin)`)
+	online := fs.Bool("online", false, "check CVE IDs exist with network access")
+	source := fs.String("source", "ubuntu", `CVE database to check with -online ("ubuntu" or "mitre")`)
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout for each request with -online")
+	debianPackage := fs.String("debian-package", "", "print the status of CVE IDs for this source package in each release from the Debian security tracker")
+	debianRelease := fs.String("debian-release", "", `Debian release like "bookworm" to print the status for with -debian-package (default: all)`)
+	addErrorsFlag(fs)
+	fs.Parse(args)
+
+	urlFormat, ok := cveLookupURLs[*source]
+	if !ok {
+		return fmt.Errorf("unknown CVE source: %s", *source)
+	}
+
+	r, err := openInput(*filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	refs, err := findCVEReferences(r)
+	if err != nil {
+		return err
+	}
+
+	var statuses map[string]debianCVEStatus
+	if *debianPackage != "" {
+		if statuses, err = fetchDebianCVEStatuses(*debianPackage); err != nil {
+			return err
+		}
+	}
+
+	name := *filename
+	if name == "-" {
+		name = "<stdin>"
+	}
+	client := &http.Client{Timeout: *timeout}
+	problems := 0
+	for _, ref := range refs {
+		if err := validateCVE(ref.id); err != nil {
+			fmt.Printf("%s:%d: %s\n", name, ref.line, err)
+			problems++
+			continue
+		}
+		if statuses != nil {
+			printDebianCVEStatus(name, ref, *debianPackage, *debianRelease, statuses)
+		}
+		if !*online {
+			continue
+		}
+		exists, err := cveExists(client, fmt.Sprintf(urlFormat, ref.id))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			fmt.Printf("%s:%d: unknown CVE ID: %s\n", name, ref.line, ref.id)
+			problems++
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("verify-cves found %d problem(s)", problems)
+	}
+	return nil
+}
+
+// debianTrackerURL is the URL of the CVE status of all source packages
+// in the Debian security tracker.
+const debianTrackerURL = "https://security-tracker.debian.org/tracker/data/json"
+
+// debianCVEStatus is the status of a CVE ID for a source package in the
+// Debian security tracker.
+type debianCVEStatus struct {
+	Releases map[string]struct {
+		Status       string `json:"status"`
+		FixedVersion string `json:"fixed_version"`
+		Urgency      string `json:"urgency"`
+	} `json:"releases"`
+}
+
+// fetchDebianCVEStatuses returns the status of CVE IDs for the source
+// package in the Debian security tracker keyed by CVE ID.
+func fetchDebianCVEStatuses(pkg string) (map[string]debianCVEStatus, error) {
+	r, err := httpGetCached(debianTrackerURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var packages map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&packages); err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %w", debianTrackerURL, err)
+	}
+	data, ok := packages[pkg]
+	if !ok {
+		return nil, fmt.Errorf("unknown source package in the Debian security tracker: %s", pkg)
+	}
+	var statuses map[string]debianCVEStatus
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %w", debianTrackerURL, err)
+	}
+	return statuses, nil
+}
+
+// printDebianCVEStatus prints the status of the CVE ID in the release,
+// or all releases if release is empty.
+func printDebianCVEStatus(name string, ref cveReference, pkg, release string, statuses map[string]debianCVEStatus) {
+	status, ok := statuses[ref.id]
+	if !ok {
+		fmt.Printf("%s:%d: %s: not tracked for %s in Debian\n", name, ref.line, ref.id, pkg)
+		return
+	}
+	releases := make([]string, 0, len(status.Releases))
+	for r := range status.Releases {
+		if release == "" || r == release {
+			releases = append(releases, r)
+		}
+	}
+	if len(releases) == 0 {
+		fmt.Printf("%s:%d: %s: not tracked for %s in Debian %s\n", name, ref.line, ref.id, pkg, release)
+		return
+	}
+	sort.Strings(releases)
+	for _, r := range releases {
+		s := status.Releases[r]
+		fmt.Printf("%s:%d: %s: %s: %s", name, ref.line, ref.id, r, s.Status)
+		if s.FixedVersion != "" && s.FixedVersion != "0" {
+			fmt.Printf(" in %s", s.FixedVersion)
+		}
+		if s.Urgency != "" {
+			fmt.Printf(" (%s)", s.Urgency)
+		}
+		fmt.Println()
+	}
+}
+
+type cveReference struct {
+	id   string
+	line int
+}
+
+// findCVEReferences returns CVE ID like strings in r with the line number
+// of their first appearance.
+func findCVEReferences(r io.Reader) ([]cveReference, error) {
+	var refs []cveReference
+	seen := make(map[string]bool)
+	br := bufio.NewReader(r)
+	lineNo := 0
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		lineNo++
+		for _, id := range cveCandidateRegex.FindAllString(line, -1) {
+			if !seen[id] {
+				seen[id] = true
+				refs = append(refs, cveReference{id: id, line: lineNo})
+			}
+		}
+	}
+	return refs, nil
+}
+
+func cveExists(client *http.Client, url string) (bool, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status %s for %s", resp.Status, url)
+	}
+}
+
+// cveGroupWriter writes a section for each CVE ID in matched entries,
+// listing the entries mentioning it. Sections are sorted by CVE ID and
+// entries are in the input order. The line of each entry is kept in
+// lines, and entries has the indexes of the lines for each CVE ID.
+type cveGroupWriter struct {
+	w       io.Writer
+	lines   *spool
+	entries map[string][]int
+}
+
+func newCVEGroupWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &cveGroupWriter{w: w, lines: newSpool(opts.maxMemory), entries: make(map[string][]int)}
+}
+
+func (c *cveGroupWriter) WriteEntry(entry Entry) error {
+	ids := uniqueSorted([]Entry{entry}, cveRegex.FindAllString)
+	if len(ids) == 0 {
+		return nil
+	}
+	line := fmt.Sprintf("  %s %s %s %s\n", entry.Package, entry.Version, entry.Distributions, entry.DateString())
+	if err := c.lines.add([]byte(line)); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		c.entries[id] = append(c.entries[id], c.lines.len()-1)
+	}
+	return nil
+}
+
+func (c *cveGroupWriter) Close() error {
+	defer c.lines.close()
+	ids := make([]string, 0, len(c.entries))
+	for id := range c.entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for i, id := range ids {
+		if i > 0 {
+			if _, err := fmt.Fprintln(c.w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(c.w, id); err != nil {
+			return err
+		}
+		for _, index := range c.entries[id] {
+			line, err := c.lines.get(index)
+			if err != nil {
+				return err
+			}
+			if _, err := c.w.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cveFlag is a flag.Value of -cve, which is given alone to match any CVE
+// ID, or with CVE IDs separated by commas to match only them. It can be
+// specified multiple times.
+type cveFlag struct {
+	set bool
+	ids []string
+}
+
+func (f *cveFlag) String() string {
+	return strings.Join(f.ids, ",")
+}
+
+func (f *cveFlag) Set(value string) error {
+	if b, err := strconv.ParseBool(value); err == nil {
+		f.set = b
+		return nil
+	}
+	ids, err := parseCVEList(value)
+	if err != nil {
+		return err
+	}
+	f.set = true
+	f.ids = append(f.ids, ids...)
+	return nil
+}
+
+// IsBoolFlag makes "-cve" valid without a value.
+func (f *cveFlag) IsBoolFlag() bool {
+	return true
+}
+
+// regexp returns the regular expression matching the CVE IDs, or any CVE
+// ID if no IDs are specified.
+func (f *cveFlag) regexp() *regexp.Regexp {
+	if len(f.ids) == 0 {
+		return cveRegex
+	}
+	quoted := make([]string, len(f.ids))
+	for i, id := range f.ids {
+		quoted[i] = regexp.QuoteMeta(id)
+	}
+	return regexp.MustCompile(`\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// parseCVEList parses CVE IDs separated by commas, converted to upper case.
+func parseCVEList(value string) ([]string, error) {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if cveRegex.FindString(id) != id {
+			return nil, fmt.Errorf("malformed CVE ID: %s", id)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// joinCVEFlagArgs joins "-cve" and the following argument into
+// "-cve=value" if the argument is a list of CVE IDs, since a boolean flag
+// does not take a separate value.
+func joinCVEFlagArgs(args []string) []string {
+	joined := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(joined, args[i:]...)
+		}
+		if (arg == "-cve" || arg == "--cve") && i+1 < len(args) {
+			if _, err := parseCVEList(args[i+1]); err == nil {
+				joined = append(joined, arg+"="+args[i+1])
+				i++
+				continue
+			}
+		}
+		joined = append(joined, arg)
+	}
+	return joined
+}
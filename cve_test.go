@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCVE(t *testing.T) {
+	for _, test := range []struct {
+		id   string
+		want string
+	}{
+		{"CVE-2024-1234", ""},
+		{"CVE-2024-123456", ""},
+		{"CVE-1999-0001", ""},
+		{"CVE-2024-123", "malformed CVE ID: CVE-2024-123"},
+		{"CVE2024-1234", "malformed CVE ID: CVE2024-1234"},
+		{"CVE-2024-1234a", "malformed CVE ID: CVE-2024-1234a"},
+		{"CVE-20241234", "malformed CVE ID: CVE-20241234"},
+		{"CVE_2024_1234", "malformed CVE ID: CVE_2024_1234"},
+		{"cve-2024-1234", "CVE ID must be in upper case: cve-2024-1234"},
+		{"Cve-2024-1234", "CVE ID must be in upper case: Cve-2024-1234"},
+		{"cve-2024-123", "malformed CVE ID: cve-2024-123"},
+		{"CVE-1998-1234", "implausible year in CVE ID: CVE-1998-1234"},
+		{"CVE-9999-1234", "implausible year in CVE ID: CVE-9999-1234"},
+	} {
+		got := ""
+		if err := validateCVE(test.id); err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("validateCVE(%q) = %q, want %q", test.id, got, test.want)
+		}
+	}
+}
+
+func TestLintCVECandidates(t *testing.T) {
+	content := "linux (6.8.0-45.45) noble; urgency=medium\n" +
+		"\n" +
+		"  * fix CVE-2024-123, CVE2024-1234 and CVE-2024-1234a\n" +
+		"    - cve-2024-5678\n" +
+		"    - CVE-2024-1234\n" +
+		"\n" +
+		" -- A B <a@b.c>  Fri, 30 Aug 2024 14:04:45 +0200\n"
+	findings, err := lintChangelog(strings.NewReader(content), defaultMaxLineLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range findings {
+		if f.rule == "cve" {
+			got = append(got, f.message)
+		}
+	}
+	want := []string{
+		"malformed CVE ID: CVE-2024-123",
+		"malformed CVE ID: CVE2024-1234",
+		"malformed CVE ID: CVE-2024-1234a",
+		"CVE ID must be in upper case: cve-2024-5678",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lintChangelog() cve findings = %q, want %q", got, want)
+	}
+}
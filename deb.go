@@ -0,0 +1,98 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// debMagic is the magic of the ar archive format used by .deb packages.
+const debMagic = "!<arch>\n"
+
+// openDebChangelog returns a reader of the changelog in a .deb package
+// read from r, which must be positioned after debMagic. The data archive
+// is read as a stream, so the package is not extracted to disk.
+func openDebChangelog(br *bufio.Reader, r io.Closer) (io.ReadCloser, error) {
+	data, err := findDebMember(br, "data.tar")
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	tr, err := decompress(io.NopCloser(data))
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	changelog, err := findDebChangelog(tar.NewReader(tr))
+	if err != nil {
+		tr.Close()
+		r.Close()
+		return nil, err
+	}
+	return openDecompressed(&decompressedReadCloser{Reader: changelog, closers: []io.Closer{tr, r}})
+}
+
+// findDebMember returns a reader of the first member of the ar archive
+// whose name starts with prefix, like "data.tar.xz" for "data.tar".
+func findDebMember(br *bufio.Reader, prefix string) (io.Reader, error) {
+	header := make([]byte, 60)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("no %s* member in .deb package", prefix)
+			}
+			return nil, fmt.Errorf("read .deb package: %w", err)
+		}
+		name := strings.TrimRight(string(header[:16]), " ")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil || string(header[58:60]) != "`\n" {
+			return nil, errors.New("invalid .deb package: broken ar member header")
+		}
+		if strings.HasPrefix(strings.TrimSuffix(name, "/"), prefix) {
+			return io.LimitReader(br, size), nil
+		}
+		// Members are aligned to even offsets.
+		if _, err := br.Discard(int(size + size%2)); err != nil {
+			return nil, fmt.Errorf("read .deb package: %w", err)
+		}
+	}
+}
+
+// findDebChangelog returns a reader of the first
+// ./usr/share/doc/PACKAGE/changelog.Debian.gz (or changelog.gz for native
+// packages) in the data archive of a .deb package.
+// Kernel image packages have a symlink to the doc directory of another
+// package instead, which is reported in the error.
+func findDebChangelog(tr *tar.Reader) (io.Reader, error) {
+	docLink := ""
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			if docLink != "" {
+				return nil, fmt.Errorf("no changelog in .deb package, %s is a symlink to the doc directory of another package", docLink)
+			}
+			return nil, errors.New("no /usr/share/doc/*/changelog.Debian.gz in .deb package")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read data archive of .deb package: %w", err)
+		}
+		name := path.Clean(strings.TrimPrefix(h.Name, "."))
+		if path.Dir(name) == "/usr/share/doc" && h.Typeflag == tar.TypeSymlink {
+			docLink = name + " -> " + h.Linkname
+			continue
+		}
+		dir, base := path.Split(name)
+		if path.Dir(path.Clean(dir)) != "/usr/share/doc" || (base != "changelog.Debian.gz" && base != "changelog.gz") {
+			continue
+		}
+		if h.Typeflag == tar.TypeSymlink {
+			return nil, fmt.Errorf("%s in .deb package is a symlink to %s, which is in another package", name, h.Linkname)
+		}
+		return tr, nil
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// compressionFormats are compressed formats detected by their magic bytes.
+// Formats without a decompressor in the standard library are decompressed
+// with the command.
+var compressionFormats = []struct {
+	name    string
+	magic   []byte
+	command []string
+}{
+	{name: "gzip", magic: []byte{0x1f, 0x8b}},
+	{name: "bzip2", magic: []byte("BZh")},
+	{name: "xz", magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, command: []string{"xz", "-dc"}},
+	{name: "zstd", magic: []byte{0x28, 0xb5, 0x2f, 0xfd}, command: []string{"zstd", "-dc"}},
+}
+
+// decompressedReadCloser reads decompressed data and closes the
+// decompressor and the compressed input on Close.
+type decompressedReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decompressedReadCloser) Close() error {
+	var firstErr error
+	for _, c := range d.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// openDecompressed returns a reader of the changelog in r if r is a .deb
+// package or a (compressed) source tarball, a reader of r decompressed if r
+// starts with magic bytes of one of compressionFormats, or r as is
+// otherwise.
+func openDecompressed(r io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(debMagic))
+	if string(head) == debMagic {
+		br.Discard(len(debMagic))
+		return openDebChangelog(br, r)
+	}
+	d, err := decompress(&decompressedReadCloser{Reader: br, closers: []io.Closer{r}})
+	if err != nil {
+		return nil, err
+	}
+	tr := bufio.NewReader(d)
+	if isTar(tr) {
+		return openTarChangelog(tr, d)
+	}
+	return &decompressedReadCloser{Reader: tr, closers: []io.Closer{d}}, nil
+}
+
+// decompress returns a reader of r decompressed if r starts with magic
+// bytes of one of compressionFormats, or r as is otherwise.
+func decompress(r io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	// The magic of xz is the longest.
+	head, _ := br.Peek(6)
+	for _, f := range compressionFormats {
+		if !bytes.HasPrefix(head, f.magic) {
+			continue
+		}
+		switch f.name {
+		case "gzip":
+			zr, err := gzip.NewReader(br)
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			return &decompressedReadCloser{Reader: zr, closers: []io.Closer{zr, r}}, nil
+		case "bzip2":
+			return &decompressedReadCloser{Reader: bzip2.NewReader(br), closers: []io.Closer{r}}, nil
+		default:
+			cmd := exec.Command(f.command[0], f.command[1:]...)
+			cmd.Stdin = br
+			cmd.Stderr = os.Stderr
+			stdout, err := cmd.StdoutPipe()
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			if err := cmd.Start(); err != nil {
+				r.Close()
+				return nil, fmt.Errorf("decompress %s input: %w", f.name, err)
+			}
+			cr := &commandReadCloser{ReadCloser: stdout, cmd: cmd, desc: f.name + " command", ignoreSIGPIPE: true}
+			return &decompressedReadCloser{Reader: cr, closers: []io.Closer{cr, r}}, nil
+		}
+	}
+	return &decompressedReadCloser{Reader: br, closers: []io.Closer{r}}, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const decompressTestChangelog = `linux (6.8.0-45.45) noble; urgency=medium
+
+  * noble/linux: 6.8.0-45.45 -proposed tracker (LP: #2078100)
+
+ -- Stefan Bader <stefan.bader@canonical.com>  Fri, 30 Aug 2024 14:04:45 +0200
+`
+
+// tarTestFile is a file in a tarball made by tarForTest. A file with
+// linkname is a symlink.
+type tarTestFile struct {
+	name, body, linkname string
+}
+
+func tarForTest(t *testing.T, files ...tarTestFile) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, f := range files {
+		h := &tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.body)), Format: tar.FormatGNU}
+		if f.linkname != "" {
+			h.Typeflag, h.Linkname, h.Size = tar.TypeSymlink, f.linkname, 0
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, f.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func gzipForTest(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// commandForTest returns the output of the command with data as stdin,
+// and skips the test if the command is not installed.
+func commandForTest(t *testing.T, data []byte, name string, args ...string) []byte {
+	t.Helper()
+	if _, err := exec.LookPath(name); err != nil {
+		t.Skipf("%s is not installed", name)
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// debForTest returns a .deb package, which is an ar archive of members
+// in the order of names and bodies.
+func debForTest(members ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString(debMagic)
+	for i := 0; i < len(members); i += 2 {
+		name, body := members[i], members[i+1]
+		fmt.Fprintf(&b, "%-16s%-12s%-6s%-6s%-8s%-10d`\n", name+"/", "0", "0", "0", "100644", len(body))
+		b.WriteString(body)
+		if len(body)%2 == 1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.Bytes()
+}
+
+func readDecompressed(data []byte) (string, error) {
+	r, err := openDecompressed(io.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(b), r.Close()
+}
+
+func TestOpenDecompressed(t *testing.T) {
+	plain := []byte(decompressTestChangelog)
+	for _, test := range []struct {
+		name string
+		data func(t *testing.T) []byte
+	}{
+		{"plain", func(t *testing.T) []byte { return plain }},
+		{"gzip", func(t *testing.T) []byte { return gzipForTest(t, plain) }},
+		{"bzip2", func(t *testing.T) []byte { return commandForTest(t, plain, "bzip2", "-c") }},
+		{"xz", func(t *testing.T) []byte { return commandForTest(t, plain, "xz", "-c") }},
+		{"zstd", func(t *testing.T) []byte { return commandForTest(t, plain, "zstd", "-c") }},
+		{"debian tarball", func(t *testing.T) []byte {
+			return tarForTest(t,
+				tarTestFile{name: "debian/control", body: "Source: linux\n"},
+				tarTestFile{name: "debian/changelog", body: decompressTestChangelog})
+		}},
+		{"source tarball", func(t *testing.T) []byte {
+			return gzipForTest(t, tarForTest(t,
+				tarTestFile{name: "./linux-6.8.0/README", body: "Linux kernel\n"},
+				tarTestFile{name: "./linux-6.8.0/foo/debian/changelog", body: "not this one\n"},
+				tarTestFile{name: "./linux-6.8.0/debian/changelog", body: decompressTestChangelog}))
+		}},
+		{"deb", func(t *testing.T) []byte {
+			data := gzipForTest(t, tarForTest(t,
+				tarTestFile{name: "./usr/share/doc/linux-image-6.8.0-45-generic", linkname: "linux-modules-6.8.0-45-generic"},
+				tarTestFile{name: "./usr/share/doc/linux-modules-6.8.0-45-generic/copyright", body: "GPL-2\n"},
+				tarTestFile{name: "./usr/share/doc/linux-modules-6.8.0-45-generic/changelog.Debian.gz", body: string(gzipForTest(t, plain))}))
+			// The odd size of debian-binary tests the padding of members.
+			return debForTest("debian-binary", "2.0\n\n\n", "control.tar.gz", "x", "data.tar.gz", string(data))
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readDecompressed(test.data(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != decompressTestChangelog {
+				t.Errorf("openDecompressed() read %q, want %q", got, decompressTestChangelog)
+			}
+		})
+	}
+}
+
+func TestOpenDecompressedErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data func(t *testing.T) []byte
+		want string
+	}{
+		{"tarball without changelog", func(t *testing.T) []byte {
+			return tarForTest(t, tarTestFile{name: "debian/control", body: "Source: linux\n"})
+		}, "no debian/changelog in tarball"},
+		{"tarball with symlink", func(t *testing.T) []byte {
+			return tarForTest(t, tarTestFile{name: "debian/changelog", linkname: "../changelog"})
+		}, "debian/changelog in tarball is not a regular file"},
+		{"deb without data", func(t *testing.T) []byte {
+			return debForTest("debian-binary", "2.0\n")
+		}, "no data.tar* member in .deb package"},
+		{"deb with broken header", func(t *testing.T) []byte {
+			return []byte(debMagic + strings.Repeat("x", 60))
+		}, "invalid .deb package: broken ar member header"},
+		{"deb without changelog", func(t *testing.T) []byte {
+			data := tarForTest(t, tarTestFile{name: "./usr/share/doc/linux-image-6.8.0-45-generic", linkname: "linux-modules-6.8.0-45-generic"})
+			return debForTest("debian-binary", "2.0\n", "data.tar", string(data))
+		}, "no changelog in .deb package, /usr/share/doc/linux-image-6.8.0-45-generic -> linux-modules-6.8.0-45-generic is a symlink to the doc directory of another package"},
+		{"deb with changelog symlink", func(t *testing.T) []byte {
+			data := tarForTest(t, tarTestFile{name: "./usr/share/doc/linux-headers/changelog.Debian.gz", linkname: "../linux/changelog.Debian.gz"})
+			return debForTest("debian-binary", "2.0\n", "data.tar", string(data))
+		}, "/usr/share/doc/linux-headers/changelog.Debian.gz in .deb package is a symlink to ../linux/changelog.Debian.gz, which is in another package"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := readDecompressed(test.data(t))
+			if err == nil || err.Error() != test.want {
+				t.Errorf("openDecompressed() error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"regexp/syntax"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+// errorsFormat is the format of fatal errors and warnings written to
+// stderr, "text" or "json". It is set with the -errors flag.
+var errorsFormat = "text"
+
+func addErrorsFlag(fs *flag.FlagSet) {
+	fs.Func("errors", `format of errors and warnings written to stderr, "text" or "json" (default "text")`, func(s string) error {
+		if s != "text" && s != "json" {
+			return fmt.Errorf(`must be "text" or "json"`)
+		}
+		errorsFormat = s
+		return nil
+	})
+}
+
+// errorReport is written to stderr as a JSON line with -errors json.
+type errorReport struct {
+	Level   string `json:"level"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+func writeErrorReport(r errorReport) {
+	if errorsFormat == "json" {
+		json.NewEncoder(os.Stderr).Encode(r)
+		return
+	}
+	switch {
+	case r.File != "" && r.Line > 0:
+		log.Printf("%s:%d: %s: %s", r.File, r.Line, r.Level, r.Message)
+	case r.File != "":
+		log.Printf("%s: %s: %s", r.File, r.Level, r.Message)
+	case r.Level == "warning":
+		log.Printf("warning: %s", r.Message)
+	default:
+		log.Print(r.Message)
+	}
+}
+
+// warnAt reports a warning at the line of the file.
+func warnAt(file string, line int, code, message string) {
+	writeErrorReport(errorReport{
+		Level:   "warning",
+		Code:    code,
+		Message: message,
+		File:    file,
+		Line:    line,
+	})
+}
+
+// exitWithError reports err and exits with the status for err.
+func exitWithError(err error) {
+	status := 1
+	if errors.Is(err, errParseWarnings) {
+		status = exitCodeParseWarnings
+	}
+	writeErrorReport(newErrorReport("error", err))
+	os.Exit(status)
+}
+
+// newErrorReport returns the report of err at level. The filename and the
+// line number of a *changelog.ParseError are reported in File and Line.
+func newErrorReport(level string, err error) errorReport {
+	r := errorReport{Level: level, Code: errorCode(err), Message: err.Error()}
+	if perr, ok := err.(*changelog.ParseError); ok {
+		msg := *perr
+		msg.Filename, msg.Line = "", 0
+		r.File, r.Line, r.Message = perr.Filename, perr.Line, msg.Error()
+	}
+	return r
+}
+
+// errorCode returns a stable identifier of the kind of err for
+// machine-readable error output.
+func errorCode(err error) string {
+	var syntaxErr *syntax.Error
+	var parseErr *changelog.ParseError
+	switch {
+	case errors.Is(err, errParseWarnings):
+		return "parse_warnings"
+	case errors.Is(err, fs.ErrNotExist):
+		return "file_not_found"
+	case errors.Is(err, fs.ErrPermission):
+		return "permission_denied"
+	case errors.As(err, &syntaxErr):
+		return "invalid_regexp"
+	case errors.As(err, &parseErr):
+		return "parse_error"
+	default:
+		return "error"
+	}
+}
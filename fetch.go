@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultFetchTimeout = 60 * time.Second
+
+var httpClient = &http.Client{
+	Timeout:       defaultFetchTimeout,
+	CheckRedirect: checkRedirect,
+}
+
+// checkRedirect follows up to 10 redirects like the default policy, but
+// refuses redirects from HTTPS to plain HTTP.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if via[len(via)-1].URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return fmt.Errorf("refused redirect from HTTPS to %s", req.URL)
+	}
+	return nil
+}
+
+// isURL returns whether filename is an HTTP or HTTPS URL to fetch the
+// changelog from.
+func isURL(filename string) bool {
+	return strings.HasPrefix(filename, "https://") || strings.HasPrefix(filename, "http://")
+}
+
+// httpStatusError is returned by httpGet for a status other than 200 OK.
+type httpStatusError struct {
+	URL        string
+	Status     string
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.URL, e.Status)
+}
+
+// httpGet sends a GET request with header and returns the response body.
+// A status other than 200 OK is returned as an *httpStatusError.
+func httpGet(url string, header http.Header) (io.ReadCloser, error) {
+	resp, err := httpDo(url, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &httpStatusError{URL: url, Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+	return resp.Body, nil
+}
+
+// httpDo sends a GET request with header and returns the response
+// regardless of its status.
+func httpDo(url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", appName+"/"+Version())
+	return httpClient.Do(req)
+}
+
+// defaultChangelogURL is the URL template of changelogs of packages in
+// the Ubuntu archive.
+const defaultChangelogURL = "https://changelogs.ubuntu.com/changelogs/pool/{pool}/{source}_{version}/changelog"
+
+// debianChangelogURL is the URL template of changelogs of packages in
+// the Debian archive.
+const debianChangelogURL = "https://metadata.ftp-master.debian.org/changelogs/{pool}/{source}_{version}_changelog"
+
+// openDebianSuiteChangelog fetches the changelog of the version of the
+// source package in the component currently in the Debian suite, like
+// "stable" or "unstable".
+func openDebianSuiteChangelog(component, pkg, suite string) (io.ReadCloser, error) {
+	return httpGetCached("https://metadata.ftp-master.debian.org/changelogs/"+poolDir(component, pkg)+"/"+suite+"_changelog", nil)
+}
+
+// mirrorConfig overrides the URL to fetch changelogs from, for sites
+// using internal mirrors. URL is a template with the placeholders below:
+//
+//	{pool}     pool directory of the source package, e.g. "main/l/linux"
+//	{source}   source package name, e.g. "linux"
+//	{package}  same as {source}
+//	{version}  version without epoch, e.g. "6.8.0-45.45"
+//
+// Username and Password are sent with basic authentication, like the
+// credentials of esm.ubuntu.com. Fallbacks are tried in order when the
+// changelog is not found, like old-releases.ubuntu.com for end-of-life
+// series.
+type mirrorConfig struct {
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	Username  string            `json:"username"`
+	Password  string            `json:"password"`
+	Fallbacks []mirrorConfig    `json:"fallbacks"`
+}
+
+// poolDir returns the directory of the source package in the pool of
+// the component, e.g. "main/l/linux" or "main/libz/libzstd".
+func poolDir(component, pkg string) string {
+	prefix := pkg[:1]
+	if strings.HasPrefix(pkg, "lib") && len(pkg) > 3 {
+		prefix = pkg[:4]
+	}
+	return component + "/" + prefix + "/" + pkg
+}
+
+func expandChangelogURL(tmpl, component, pkg, version string) string {
+	if _, v, ok := strings.Cut(version, ":"); ok {
+		version = v
+	}
+	return strings.NewReplacer(
+		"{pool}", poolDir(component, pkg),
+		"{source}", pkg,
+		"{package}", pkg,
+		"{version}", version,
+	).Replace(tmpl)
+}
+
+// openPackageChangelog fetches the changelog of the version of the source
+// package in the component from the mirror and its fallbacks, or the
+// Ubuntu archive if mirror is nil.
+func openPackageChangelog(mirror *mirrorConfig, component, pkg, version string) (io.ReadCloser, error) {
+	if mirror == nil {
+		mirror = &mirrorConfig{}
+	}
+	var err error
+	for _, m := range append([]mirrorConfig{*mirror}, mirror.Fallbacks...) {
+		tmpl := m.URL
+		if tmpl == "" {
+			tmpl = defaultChangelogURL
+		}
+		header := http.Header{}
+		for k, v := range m.Headers {
+			header.Set(k, v)
+		}
+		if m.Username != "" || m.Password != "" {
+			header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(m.Username+":"+m.Password)))
+		}
+		var body io.ReadCloser
+		body, err = httpGetCached(expandChangelogURL(tmpl, component, pkg, version), header)
+		var statusErr *httpStatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			return body, err
+		}
+	}
+	return nil, err
+}
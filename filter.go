@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+// Match modes of -match-mode.
+const (
+	matchModeAny = "any"
+	matchModeAll = "all"
+)
+
+// newChangeFilter returns a filter of patterns combined with mode,
+// matchModeAny or matchModeAll.
+func newChangeFilter(patterns []*regexp.Regexp, mode string) (*changelog.PatternFilter, error) {
+	switch mode {
+	case matchModeAny, matchModeAll:
+	default:
+		return nil, fmt.Errorf("invalid match mode %q, must be %q or %q", mode, matchModeAny, matchModeAll)
+	}
+	return &changelog.PatternFilter{Patterns: patterns, All: mode == matchModeAll}, nil
+}
+
+// newOptionsFilter returns the filter of -filter, -glob, -filter-file,
+// -cve, -lp, -subsystem, -upstream, -query and -exclude, which selects
+// all changes with all details if none of them are specified.
+func newOptionsFilter(opts options) (*changelog.PatternFilter, error) {
+	exprs := quoteFilters(opts.filters, opts.fixedStrings)
+	for _, glob := range opts.globs {
+		exprs = append(exprs, globToRegexp(glob))
+	}
+	// Patterns in a file are a single filter which matches if any of
+	// them matches.
+	for _, filename := range opts.filterFiles {
+		lines, err := readPatternFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		lines = quoteFilters(lines, opts.fixedStrings)
+		for i, line := range lines {
+			lines[i] = "(?:" + line + ")"
+		}
+		exprs = append(exprs, strings.Join(lines, "|"))
+	}
+	if opts.cve.set {
+		exprs = append(exprs, opts.cve.regexp().String())
+	}
+	if len(opts.lpBugs) > 0 {
+		exprs = append(exprs, opts.lpBugs.regexp().String())
+	}
+	if len(opts.subsystems) > 0 {
+		exprs = append(exprs, subsystemRegexp(opts.subsystems).String())
+	}
+	var upstreamRE *regexp.Regexp
+	if len(opts.upstream) > 0 {
+		upstreamRE = upstreamStableRegexp(opts.upstream)
+		exprs = append(exprs, upstreamRE.String())
+	}
+	patterns, err := compileFilters(exprs, opts.ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := newChangeFilter(patterns, opts.matchMode)
+	if err != nil {
+		return nil, err
+	}
+	filter.Excludes, err = compileFilters(quoteFilters(opts.excludes, opts.fixedStrings), opts.ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	filter.MatchHeader = opts.matchHeader
+	if upstreamRE != nil {
+		filter.SummaryPatterns = append(filter.SummaryPatterns, upstreamRE)
+	}
+	if opts.query != "" {
+		q, err := parseQuery(opts.query, opts.ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+		filter.Predicate = q.root.eval
+		filter.TextPatterns = q.textPatterns
+	}
+	return filter, nil
+}
+
+// globToRegexp converts a shell-style wildcard pattern matching a whole
+// line like "*ext4*" to a regular expression. "*" matches any string, "?"
+// matches any character, and "[...]" matches a character in it, or not in
+// it if it starts with "!".
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// upstreamStableRegexp returns the regular expression matching summaries
+// of upstream stable updates of the Ubuntu kernel to the versions like
+// "Noble update: v6.8.7 upstream stable release" or "upstream stable to
+// v6.8.7".
+func upstreamStableRegexp(versions []string) *regexp.Regexp {
+	var quoted []string
+	for _, list := range versions {
+		for _, v := range strings.Split(list, ",") {
+			quoted = append(quoted, regexp.QuoteMeta(strings.TrimPrefix(strings.TrimSpace(v), "v")))
+		}
+	}
+	v := `\bv?(?:` + strings.Join(quoted, "|") + `)\b`
+	return regexp.MustCompile(`(?i)` + v + `.*\bupstream stable\b|\bupstream stable\b.*` + v)
+}
+
+// readPatternFile reads a pattern for each line of filename, skipping
+// empty lines and comment lines starting with "#".
+func readPatternFile(filename string) ([]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no patterns in %s", filename)
+	}
+	return patterns, nil
+}
+
+// quoteFilters returns exprs with metacharacters quoted if fixed is true,
+// so that they match literally.
+func quoteFilters(exprs []string, fixed bool) []string {
+	quoted := make([]string, len(exprs))
+	for i, expr := range exprs {
+		if fixed {
+			expr = regexp.QuoteMeta(expr)
+		}
+		quoted[i] = expr
+	}
+	return quoted
+}
+
+// compileFilters compiles regular expressions, with the "i" flag if
+// ignoreCase is true.
+func compileFilters(exprs []string, ignoreCase bool) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, expr := range exprs {
+		if ignoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
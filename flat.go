@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// flatHeader is the column names of rows returned by flattenEntry.
+var flatHeader = []string{"package", "version", "distributions", "date", "maintainer_name", "email_address", "summary", "detail"}
+
+// flattenEntry returns a row for each detail of changes in entry, or for
+// each change without details, for tabular output formats.
+func flattenEntry(entry Entry) [][]string {
+	var rows [][]string
+	row := func(summary, detail string) []string {
+		return []string{entry.Package, entry.Version, entry.Distributions, entry.DateString(),
+			entry.MaintainerName, entry.EmailAddress, summary, detail}
+	}
+	for _, change := range entry.Changes {
+		if len(change.Details) == 0 {
+			rows = append(rows, row(change.Summary, ""))
+			continue
+		}
+		for _, detail := range change.Details {
+			rows = append(rows, row(change.Summary, strings.Join(detail.Lines, " ")))
+		}
+	}
+	return rows
+}
+
+// csvWriter writes flattened entries as CSV with a header line.
+type csvWriter struct {
+	w     *csv.Writer
+	count int
+}
+
+func newCSVWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) WriteEntry(entry Entry) error {
+	if c.count == 0 {
+		c.w.Write(flatHeader)
+	}
+	c.count++
+	c.w.WriteAll(flattenEntry(entry))
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if c.count == 0 {
+		c.w.Write(flatHeader)
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// tsvWriter writes flattened entries as tab separated values with a header
+// line. Fields are not quoted, tabs in fields are replaced with spaces.
+type tsvWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newTSVWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &tsvWriter{w: w}
+}
+
+var tsvFieldReplacer = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+func (t *tsvWriter) writeRow(row []string) error {
+	fields := make([]string, len(row))
+	for i, f := range row {
+		fields[i] = tsvFieldReplacer.Replace(f)
+	}
+	_, err := io.WriteString(t.w, strings.Join(fields, "\t")+"\n")
+	return err
+}
+
+func (t *tsvWriter) WriteEntry(entry Entry) error {
+	if t.count == 0 {
+		if err := t.writeRow(flatHeader); err != nil {
+			return err
+		}
+	}
+	t.count++
+	for _, row := range flattenEntry(entry) {
+		if err := t.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tsvWriter) Close() error {
+	if t.count == 0 {
+		return t.writeRow(flatHeader)
+	}
+	return nil
+}
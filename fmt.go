@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+func runFmt(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	filename := fs.String("file", "-", `changelog filename ("-" for stdin)`)
+	width := fs.Int("width", changelog.DefaultWrapWidth, "wrap change lines longer than this column (0 for no wrapping)")
+	addErrorsFlag(fs)
+	fs.Parse(args)
+
+	r, err := openInput(*filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := formatChangelog(w, r, *width); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// formatChangelog reads a changelog from r and writes it to w in the
+// canonical format. Indentation of bullets, blank lines between parts of
+// entries and spacing in trailer lines are normalized and change lines
+// longer than width are wrapped, while the text is kept as is.
+func formatChangelog(w io.Writer, r io.Reader, width int) error {
+	f := &changelogFormatter{w: w, width: width}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" {
+			break
+		}
+		f.processLine(strings.TrimRight(line, " \t\r\n"))
+	}
+	f.flushEntry()
+	return nil
+}
+
+type changelogFormatter struct {
+	w     io.Writer
+	width int
+
+	wroteAny     bool
+	pendingBlank bool
+	inEntry      bool
+	heading      string
+	body         []string
+	// state is where the last body line is, like the parser of the
+	// changelog package, so that lines are classified in the same way.
+	state fmtState
+	// changeIndent is the indentation of the bullet of the last change
+	// and detailColumn is the column of the text of the last detail.
+	changeIndent int
+	detailColumn int
+}
+
+type fmtState int
+
+const (
+	fmtStateInEntry fmtState = iota
+	fmtStateInChange
+	fmtStateInDetail
+)
+
+func (f *changelogFormatter) processLine(line string) {
+	if line == "" {
+		if f.inEntry {
+			f.body = append(f.body, "")
+		} else {
+			f.pendingBlank = true
+		}
+		return
+	}
+
+	indented := line[0] == ' ' || line[0] == '\t'
+	if !indented {
+		if entry, err := changelog.ParseEntryLine(line); err == nil {
+			f.flushEntry()
+			f.inEntry = true
+			f.heading = fmt.Sprintf("%s (%s) %s; %s", entry.Package, entry.Version,
+				strings.Join(strings.Fields(entry.Distributions), " "), entry.Metadata)
+			f.state = fmtStateInEntry
+			return
+		}
+	}
+	if !f.inEntry {
+		if f.pendingBlank && f.wroteAny {
+			f.writeLine("")
+		}
+		f.pendingBlank = false
+		f.writeLine(line)
+		return
+	}
+	if strings.HasPrefix(strings.TrimLeft(line, " \t"), "-- ") {
+		trailer, ok := canonicalMaintainerLine(line)
+		if !ok {
+			trailer = line
+		}
+		f.flushEntry()
+		f.writeLine(trailer)
+		return
+	}
+	f.body = append(f.body, f.formatBodyLine(line)...)
+}
+
+// formatBodyLine returns line reindented with the canonical bullets and
+// wrapped. Changes and details are recognized like the parser does, so
+// that the formatted changelog is parsed to the same entries. Lines
+// nested deeper than the text of a detail keep their extra indentation.
+func (f *changelogFormatter) formatBodyLine(line string) []string {
+	text := strings.TrimLeft(line, " \t")
+	indent := len(line) - len(text)
+	bullet := indent > 0 && len(text) >= 2 && strings.IndexByte("*-+", text[0]) != -1 && text[1] == ' '
+	switch {
+	case bullet && (f.state == fmtStateInEntry || indent <= f.changeIndent):
+		f.state = fmtStateInChange
+		f.changeIndent = indent
+		return changelog.WrapText(changelog.ChangePrefix, changelog.ChangeTailPrefix, strings.TrimLeft(text[2:], " "), f.width)
+	case f.state == fmtStateInDetail && indent >= f.detailColumn:
+		prefix := changelog.DetailTailPrefix + line[f.detailColumn:indent]
+		return changelog.WrapText(prefix, prefix, text, f.width)
+	case bullet:
+		f.state = fmtStateInDetail
+		body := strings.TrimLeft(text[2:], " ")
+		f.detailColumn = len(line) - len(body)
+		return changelog.WrapText(changelog.DetailHeadPrefix, changelog.DetailTailPrefix, body, f.width)
+	case f.state == fmtStateInChange && indent > f.changeIndent:
+		return changelog.WrapText(changelog.ChangeTailPrefix, changelog.ChangeTailPrefix, text, f.width)
+	case strings.HasPrefix(text, "[ ") && strings.HasSuffix(text, "]"),
+		f.state != fmtStateInEntry && indent > len(changelog.ChangePrefix)-2:
+		// Lines like "[ John Doe ]" which are not continuation lines are
+		// indented less than the text of changes to stay so.
+		return []string{changelog.ChangePrefix[:2] + text}
+	default:
+		return []string{line}
+	}
+}
+
+// canonicalMaintainerLine returns the trailer line with a single space
+// between the name and the email address and two spaces before the date.
+func canonicalMaintainerLine(line string) (string, bool) {
+	line = changelog.MaintainerLinePrefix + strings.TrimLeft(strings.TrimLeft(line, " \t")[2:], " ")
+	var entry Entry
+	if err := changelog.ParseMaintainerLine(&entry, line); err != nil {
+		return "", false
+	}
+	_, afterEmail, _ := strings.Cut(line, ">")
+	return fmt.Sprintf("%s%s <%s>  %s", changelog.MaintainerLinePrefix, strings.TrimRight(entry.MaintainerName, " "),
+		entry.EmailAddress, strings.TrimLeft(afterEmail, " ")), true
+}
+
+// flushEntry writes the heading and the change lines of the current entry
+// with exactly one blank line after the heading, between groups of changes
+// and before the trailer line.
+func (f *changelogFormatter) flushEntry() {
+	if !f.inEntry {
+		return
+	}
+	if f.wroteAny {
+		f.writeLine("")
+	}
+	f.writeLine(f.heading)
+	f.writeLine("")
+	blank := false
+	wroteBody := false
+	for _, line := range f.body {
+		if line == "" {
+			blank = wroteBody
+			continue
+		}
+		if blank {
+			f.writeLine("")
+			blank = false
+		}
+		f.writeLine(line)
+		wroteBody = true
+	}
+	if wroteBody {
+		f.writeLine("")
+	}
+	f.inEntry = false
+	f.pendingBlank = false
+	f.heading = ""
+	f.body = nil
+}
+
+func (f *changelogFormatter) writeLine(line string) {
+	fmt.Fprintln(f.w, line)
+	f.wroteAny = true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+const fmtTestChangelog = `linux (6.8.0-45.45) noble; urgency=medium
+
+  * noble/linux: 6.8.0-45.45 -proposed tracker (LP: #2078100)
+
+  [ Ubuntu: 6.8.0-45.45 ]
+
+  * Fix the frobnicator so that it works with aaa - bbb ccc and also with the other thing - really
+    - mm: a detail which is long enough to be wrapped * at the star and - at the dash in it
+      - nested detail line which is also long enough to be wrapped - somewhere
+        deeper nested line
+    - second detail
+  * A summary which wraps
+    onto a continuation line
+
+ -- Stefan Bader <stefan.bader@canonical.com>  Fri, 30 Aug 2024 14:04:45 +0200
+
+binutils (2.17-1) unstable; urgency=low
+
+   * New upstream release.
+     - 120_mips_xgot_multigot_workaround.dpatch: removed - superseded by a
+       proper fix upstream.  Closes: #274738
+     + debian/binutils.shlibs: updated SONAME to 2.17.
+   * Second change.
+
+ -- James Troup <james@nocrew.org>  Mon, 26 Jun 2006 13:17:36 +0100
+
+foo (1.0-1) unstable; urgency=medium
+
+  - Top level change bulleted with a dash
+    * detail bulleted with a star
+  - Another change
+
+ -- John Doe <john@example.com>  Mon, 26 Jun 2006 13:17:36 +0100
+`
+
+// parseForTest parses text and returns the entries as JSON. If normalize
+// is true, the text of each detail is joined into one line and runs of
+// spaces are squeezed, so that entries only differ in wrapping compare
+// equal.
+func parseForTest(t *testing.T, text string, normalize bool) string {
+	t.Helper()
+	entries, err := changelog.Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range entries {
+		entries[i].ExtraLines = trimLines(entries[i].ExtraLines)
+		for j := range entries[i].Changes {
+			change := &entries[i].Changes[j]
+			change.ExtraLines = trimLines(change.ExtraLines)
+			if !normalize {
+				continue
+			}
+			change.Summary = strings.Join(strings.Fields(change.Summary), " ")
+			for k := range change.Details {
+				joined := strings.Join(change.Details[k].Lines, " ")
+				change.Details[k].Lines = []string{strings.Join(strings.Fields(joined), " ")}
+			}
+		}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func trimLines(lines []string) []string {
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return lines
+}
+
+func TestFormatChangelogRoundTrip(t *testing.T) {
+	for _, width := range []int{0, 30, 50, changelog.DefaultWrapWidth} {
+		var b bytes.Buffer
+		if err := formatChangelog(&b, strings.NewReader(fmtTestChangelog), width); err != nil {
+			t.Fatal(err)
+		}
+		normalize := width > 0
+		want := parseForTest(t, fmtTestChangelog, normalize)
+		if got := parseForTest(t, b.String(), normalize); got != want {
+			t.Errorf("width %d: formatted changelog is parsed differently\nformatted:\n%s\ngot: %s\nwant: %s",
+				width, b.String(), got, want)
+		}
+	}
+}
+
+func TestFormatChangelogKeepsNesting(t *testing.T) {
+	var b bytes.Buffer
+	if err := formatChangelog(&b, strings.NewReader(fmtTestChangelog), 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"      - nested detail line which is also long enough to be wrapped - somewhere",
+		"        deeper nested line",
+		"    - detail bulleted with a star",
+		"  * Top level change bulleted with a dash",
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("formatted changelog does not have %q:\n%s", line, b.String())
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// gitRevisions returns the tags of the git repository newest first, or
+// the commits in revRange changing path if revRange is not empty.
+// Revisions without path are excluded.
+func gitRevisions(repo, path, revRange string) ([]string, error) {
+	args := []string{"for-each-ref", "--sort=-creatordate", "--format=%(refname:short)", "refs/tags"}
+	if revRange != "" {
+		args = []string{"rev-list", "--abbrev-commit", revRange, "--", path}
+	}
+	out, err := runGit(repo, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	revs := strings.Fields(string(out))
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("no revisions in git repository %s", repo)
+	}
+
+	var objects bytes.Buffer
+	for _, rev := range revs {
+		objects.WriteString(rev + ":" + path + "\n")
+	}
+	out, err = runGit(repo, &objects, "cat-file", "--batch-check")
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	for i, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+		if i < len(revs) && !strings.HasSuffix(line, " missing") {
+			found = append(found, revs[i])
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("no %s in revisions of git repository %s", path, repo)
+	}
+	return found, nil
+}
+
+// runGit runs the git command in repo and returns stdout.
+func runGit(repo string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	cmd.Stdin = stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// openGitChangelog returns a reader of path at rev in the git repository.
+func openGitChangelog(repo, rev, path string) (io.ReadCloser, error) {
+	cmd := exec.Command("git", "-C", repo, "cat-file", "blob", rev+":"+path)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandReadCloser{ReadCloser: stdout, cmd: cmd, desc: "git command", ignoreSIGPIPE: true}, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// htmlTemplate renders a standalone page with "head", "entry" for each
+// entry and "foot". Each entry has the package and the version like
+// "linux-6.8.0-45.45" as the anchor, and each CVE mention has the CVE ID as
+// the anchor. Later entries and mentions with the same anchor have a
+// sequence number like "CVE-2024-26800-2" appended to it.
+const htmlTemplate = `{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{with .Package}}{{.}} {{end}}changelog</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 0 auto; padding: 1em; }
+.meta { color: #555; }
+</style>
+</head>
+<body>
+{{- with .CVEs}}
+<nav>
+<h2>CVEs</h2>
+<ul>
+{{- range .}}
+<li><a href="#{{.}}">{{.}}</a></li>
+{{- end}}
+</ul>
+</nav>
+{{- end}}
+{{- end}}
+
+{{- define "entry"}}
+<section>
+{{- $anchor := entryAnchor .}}
+<h2 id="{{$anchor}}"><a href="#{{$anchor}}">{{.Package}} {{.Version}}</a></h2>
+<p class="meta">{{.Distributions}}; {{.Metadata}}<br>{{.MaintainerName}} &lt;{{.EmailAddress}}&gt; {{formatDate .}}</p>
+{{- if .Changes}}
+<ul>
+{{- range .Changes}}
+<li>{{linkCVEs .Summary}}
+{{- if .Details}}
+<ul>
+{{- range .Details}}
+<li>{{linkCVEs (join .Lines " ")}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</li>
+{{- end}}
+</ul>
+{{- end}}
+</section>
+{{- end}}
+
+{{- define "foot"}}
+<p class="meta">Generated at {{formatDate .}}</p>
+</body>
+</html>
+{{end}}`
+
+// htmlWriter renders each matched entry as a section kept in sections,
+// and writes the page with the list of CVE IDs in all entries before
+// them in Close.
+type htmlWriter struct {
+	w        io.Writer
+	tmpl     *template.Template
+	pkg      string
+	cves     map[string]bool
+	sections *spool
+	buf      bytes.Buffer
+}
+
+func newHTMLWriter(w io.Writer, opts outputOptions) entryWriter {
+	mentions := make(map[string]int)
+	tmpl := template.Must(template.New("html").Funcs(template.FuncMap{
+		"join":       strings.Join,
+		"formatDate": templateFuncs["formatDate"],
+		"entryAnchor": func(entry Entry) string {
+			anchor := entry.Version
+			if entry.Package != "" {
+				anchor = entry.Package + "-" + anchor
+			}
+			return uniqueAnchor(anchor, mentions)
+		},
+		"linkCVEs": func(s string) template.HTML {
+			return linkCVEs(s, mentions)
+		},
+	}).Parse(htmlTemplate))
+	return &htmlWriter{w: w, tmpl: tmpl, cves: make(map[string]bool), sections: newSpool(opts.maxMemory)}
+}
+
+func (h *htmlWriter) WriteEntry(entry Entry) error {
+	if h.sections.len() == 0 {
+		h.pkg = entry.Package
+	}
+	for _, id := range uniqueSorted([]Entry{entry}, cveRegex.FindAllString) {
+		h.cves[id] = true
+	}
+	h.buf.Reset()
+	if err := h.tmpl.ExecuteTemplate(&h.buf, "entry", entry); err != nil {
+		return err
+	}
+	return h.sections.add(h.buf.Bytes())
+}
+
+func (h *htmlWriter) Close() error {
+	defer h.sections.close()
+	cves := make([]string, 0, len(h.cves))
+	for id := range h.cves {
+		cves = append(cves, id)
+	}
+	sort.Strings(cves)
+	err := h.tmpl.ExecuteTemplate(h.w, "head", struct {
+		Package string
+		CVEs    []string
+	}{h.pkg, cves})
+	if err != nil {
+		return err
+	}
+	if _, err := h.sections.WriteTo(h.w); err != nil {
+		return err
+	}
+	return h.tmpl.ExecuteTemplate(h.w, "foot", time.Now())
+}
+
+// uniqueAnchor returns anchor with the sequence number of it appended if
+// it is not the first one counted in mentions.
+func uniqueAnchor(anchor string, mentions map[string]int) string {
+	mentions[anchor]++
+	if n := mentions[anchor]; n > 1 {
+		return anchor + "-" + strconv.Itoa(n)
+	}
+	return anchor
+}
+
+// linkCVEs returns HTML escaped s with CVE IDs wrapped in anchors.
+// mentions counts the anchors so far to make them unique.
+func linkCVEs(s string, mentions map[string]int) template.HTML {
+	var b strings.Builder
+	last := 0
+	for _, loc := range cveRegex.FindAllStringIndex(s, -1) {
+		id := s[loc[0]:loc[1]]
+		b.WriteString(template.HTMLEscapeString(s[last:loc[0]]))
+		b.WriteString(`<a id="` + uniqueAnchor(id, mentions) + `" href="https://ubuntu.com/security/` + id + `">` + id + `</a>`)
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(s[last:]))
+	return template.HTML(b.String())
+}
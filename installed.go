@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+// docDir is the directory of documents of installed packages.
+var docDir = "/usr/share/doc"
+
+// installedChangelogNames are changelog filenames in the doc directory of
+// a package in the order of preference. changelog.gz is the Debian
+// changelog of native packages.
+var installedChangelogNames = []string{"changelog.Debian.gz", "changelog.Debian", "changelog.gz", "changelog"}
+
+// openInstalledChangelog opens the changelog of the installed package pkg.
+// If pkg is "running", the image package of the running kernel is used.
+func openInstalledChangelog(pkg string) (io.ReadCloser, error) {
+	if pkg == "running" {
+		release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+		if err != nil {
+			return nil, fmt.Errorf("detect running kernel: %w", err)
+		}
+		pkg = "linux-image-" + strings.TrimSpace(string(release))
+	}
+	pkg, _, _ = strings.Cut(pkg, ":")
+	for _, name := range installedChangelogNames {
+		filename := filepath.Join(docDir, pkg, name)
+		r, err := openInput(filename)
+		if err == nil {
+			return r, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("no changelog of installed package %s in %s", pkg, filepath.Join(docDir, pkg))
+}
+
+// installedSourceVersions returns the newest installed version of each
+// source package, read with dpkg-query. Multiple versions of a source
+// package are installed like kernels of different ABIs.
+func installedSourceVersions() (map[string]string, error) {
+	cmd := exec.Command("dpkg-query", "-W", "-f", "${source:Package}\t${source:Version}\t${db:Status-Abbrev}\n")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("dpkg-query: %w", err)
+	}
+	versions := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || !strings.HasPrefix(fields[2], "ii") {
+			continue
+		}
+		pkg, version := fields[0], fields[1]
+		if v, ok := versions[pkg]; !ok || changelog.CompareVersions(version, v) > 0 {
+			versions[pkg] = version
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+const launchpadAPIBaseURL = "https://api.launchpad.net/1.0"
+
+// launchpadConfig has credentials for the Launchpad API, which are needed
+// for private PPAs. They can be obtained with launchpadlib or by
+// following https://help.launchpad.net/API/SigningRequests.
+type launchpadConfig struct {
+	ConsumerKey string `json:"consumer_key"`
+	Token       string `json:"token"`
+	TokenSecret string `json:"token_secret"`
+}
+
+// header returns the Authorization header with OAuth PLAINTEXT signature,
+// or nil if no credentials are configured.
+func (c *launchpadConfig) header() http.Header {
+	if c == nil || c.Token == "" {
+		return nil
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	params := []string{
+		`OAuth realm="https://api.launchpad.net/"`,
+		`oauth_consumer_key="` + url.QueryEscape(c.ConsumerKey) + `"`,
+		`oauth_token="` + url.QueryEscape(c.Token) + `"`,
+		`oauth_signature_method="PLAINTEXT"`,
+		`oauth_signature="&` + url.QueryEscape(c.TokenSecret) + `"`,
+		`oauth_timestamp="` + strconv.FormatInt(time.Now().Unix(), 10) + `"`,
+		`oauth_nonce="` + hex.EncodeToString(nonce) + `"`,
+		`oauth_version="1.0"`,
+	}
+	return http.Header{"Authorization": {strings.Join(params, ", ")}}
+}
+
+// launchpadGetJSON gets a Launchpad API resource and decodes it into v.
+func launchpadGetJSON(cfg *launchpadConfig, u string, v any) error {
+	body, err := httpGet(u, cfg.header())
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(v)
+}
+
+type launchpadSourcePublication struct {
+	SelfLink             string `json:"self_link"`
+	SourcePackageVersion string `json:"source_package_version"`
+	ComponentName        string `json:"component_name"`
+}
+
+// findPublishedSources returns published sources of pkg in the archive in
+// the order of the Launchpad API, which is not the order of versions.
+// series may be empty to search all series.
+func findPublishedSources(cfg *launchpadConfig, archiveURL, pkg, series string) ([]launchpadSourcePublication, error) {
+	q := url.Values{
+		"ws.op":       {"getPublishedSources"},
+		"source_name": {pkg},
+		"exact_match": {"true"},
+		"status":      {"Published"},
+	}
+	if series != "" {
+		q.Set("distro_series", launchpadAPIBaseURL+"/ubuntu/"+series)
+	}
+	var result struct {
+		Entries []launchpadSourcePublication `json:"entries"`
+	}
+	if err := launchpadGetJSON(cfg, archiveURL+"?"+q.Encode(), &result); err != nil {
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+// openPublicationChangelog fetches the changelog of a published source.
+func openPublicationChangelog(cfg *launchpadConfig, pub launchpadSourcePublication) (io.ReadCloser, error) {
+	var changelogURL string
+	if err := launchpadGetJSON(cfg, pub.SelfLink+"?ws.op=changelogUrl", &changelogURL); err != nil {
+		return nil, err
+	}
+	if changelogURL == "" {
+		return nil, fmt.Errorf("no changelog for %s", pub.SelfLink)
+	}
+	return httpGetCached(changelogURL, cfg.header())
+}
+
+// openPPAChangelog fetches the changelog of the latest published source
+// of pkg in the PPA specified in the form of "team/name".
+func openPPAChangelog(cfg *launchpadConfig, ppa, pkg, series string) (io.ReadCloser, error) {
+	team, name, ok := strings.Cut(ppa, "/")
+	if !ok || team == "" || name == "" {
+		return nil, fmt.Errorf("invalid PPA %q, must be in the form of team/name", ppa)
+	}
+	team = strings.TrimPrefix(team, "ppa:")
+	archiveURL := fmt.Sprintf("%s/~%s/+archive/ubuntu/%s", launchpadAPIBaseURL, url.PathEscape(team), url.PathEscape(name))
+	pubs, err := findPublishedSources(cfg, archiveURL, pkg, series)
+	if err != nil {
+		return nil, err
+	}
+	if len(pubs) == 0 {
+		return nil, fmt.Errorf("no published source of %s in ppa:%s", pkg, ppa)
+	}
+	return openPublicationChangelog(cfg, newestPublication(pubs))
+}
+
+// newestPublication returns the publication of the highest version in
+// pubs, which must not be empty.
+func newestPublication(pubs []launchpadSourcePublication) launchpadSourcePublication {
+	newest := pubs[0]
+	for _, pub := range pubs[1:] {
+		if changelog.CompareVersions(pub.SourcePackageVersion, newest.SourcePackageVersion) > 0 {
+			newest = pub
+		}
+	}
+	return newest
+}
+
+// findSourceVersions returns publications of each version of pkg in the
+// Ubuntu primary archive, newest first, including superseded ones.
+// series and pocket like "Security" may be empty for all of them.
+func findSourceVersions(cfg *launchpadConfig, pkg, series, pocket string) ([]launchpadSourcePublication, error) {
+	q := url.Values{
+		"ws.op":       {"getPublishedSources"},
+		"source_name": {pkg},
+		"exact_match": {"true"},
+	}
+	if series != "" {
+		q.Set("distro_series", launchpadAPIBaseURL+"/ubuntu/"+series)
+	}
+	if pocket != "" {
+		q.Set("pocket", pocket)
+	}
+	var pubs []launchpadSourcePublication
+	seen := make(map[string]bool)
+	next := launchpadAPIBaseURL + "/ubuntu/+archive/primary?" + q.Encode()
+	for next != "" {
+		var result struct {
+			Entries            []launchpadSourcePublication `json:"entries"`
+			NextCollectionLink string                       `json:"next_collection_link"`
+		}
+		if err := launchpadGetJSON(cfg, next, &result); err != nil {
+			return nil, err
+		}
+		// A version is published in multiple pockets and series.
+		for _, pub := range result.Entries {
+			if !seen[pub.SourcePackageVersion] {
+				seen[pub.SourcePackageVersion] = true
+				pubs = append(pubs, pub)
+			}
+		}
+		next = result.NextCollectionLink
+	}
+	sort.Slice(pubs, func(i, j int) bool {
+		return changelog.CompareVersions(pubs[i].SourcePackageVersion, pubs[j].SourcePackageVersion) > 0
+	})
+	return pubs, nil
+}
+
+// openSeriesChangelog fetches the changelog of the newest version of pkg
+// published in the series of the Ubuntu primary archive in any pocket.
+// The version is looked up with the Launchpad API, and the changelog is
+// fetched from the mirror, or changelogs.ubuntu.com if mirror is nil.
+func openSeriesChangelog(cfg *launchpadConfig, mirror *mirrorConfig, pkg, series string) (io.ReadCloser, error) {
+	pubs, err := findPublishedSources(cfg, launchpadAPIBaseURL+"/ubuntu/+archive/primary", pkg, series)
+	if err != nil {
+		return nil, err
+	}
+	if len(pubs) == 0 {
+		return nil, fmt.Errorf("no published source of %s in %s", pkg, series)
+	}
+	newest := newestPublication(pubs)
+	component := newest.ComponentName
+	if component == "" {
+		component = "main"
+	}
+	return openPackageChangelog(mirror, component, pkg, newest.SourcePackageVersion)
+}
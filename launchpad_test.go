@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestNewestPublication(t *testing.T) {
+	// The Launchpad API lists publications by the date and the series,
+	// not by the version.
+	pubs := []launchpadSourcePublication{
+		{SourcePackageVersion: "6.8.0-9.9"},
+		{SourcePackageVersion: "6.8.0-45.45"},
+		{SourcePackageVersion: "6.8.0-45.45~22.04.1"},
+		{SourcePackageVersion: "6.8.0-11.11"},
+	}
+	if got := newestPublication(pubs).SourcePackageVersion; got != "6.8.0-45.45" {
+		t.Errorf("newestPublication() = %s, want 6.8.0-45.45", got)
+	}
+}
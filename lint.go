@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+type severity int
+
+const (
+	severityWarning severity = iota
+	severityError
+)
+
+func (s severity) String() string {
+	switch s {
+	case severityWarning:
+		return "warning"
+	case severityError:
+		return "error"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+type lintFinding struct {
+	line     int
+	severity severity
+	rule     string
+	message  string
+}
+
+const defaultMaxLineLength = 80
+
+// knownSeries are Debian and Ubuntu release names accepted in
+// the distributions field of entry lines.
+var knownSeries = []string{
+	"UNRELEASED", "unstable", "experimental", "testing", "stable", "oldstable", "oldoldstable",
+	"hamm", "slink", "potato", "woody", "sarge", "etch", "lenny", "squeeze",
+	"wheezy", "jessie", "stretch", "buster", "bullseye", "bookworm", "trixie",
+	"forky", "duke", "sid",
+	"warty", "hoary", "breezy", "dapper", "edgy", "feisty", "gutsy", "hardy",
+	"intrepid", "jaunty", "karmic", "lucid", "maverick", "natty", "oneiric",
+	"precise", "quantal", "raring", "saucy", "trusty", "utopic", "vivid",
+	"wily", "xenial", "yakkety", "zesty", "artful", "bionic", "cosmic",
+	"disco", "eoan", "focal", "groovy", "hirsute", "impish", "jammy",
+	"kinetic", "lunar", "mantic", "noble", "oracular", "plucky", "questing",
+}
+
+// knownPockets are suffixes which may follow a series name in
+// the distributions field. The last ones are only used by Debian.
+var knownPockets = []string{
+	"", "-security", "-updates", "-proposed", "-backports",
+	"-proposed-updates", "-backports-sloppy", "-lts",
+}
+
+func isKnownDistribution(dist string) bool {
+	for _, series := range knownSeries {
+		if pocket, ok := strings.CutPrefix(dist, series); ok {
+			for _, p := range knownPockets {
+				if pocket == p {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// fixableRules are the lint rules whose findings are repaired by
+// fixChangelog.
+var fixableRules = map[string]bool{
+	"trailing-whitespace": true,
+	"trailer-spacing":     true,
+	"indentation":         true,
+	"line-length":         true,
+}
+
+// builtinLintProfiles are lint profiles available without a config file.
+// Profiles with the same names in the config file take precedence.
+var builtinLintProfiles = map[string]lintProfile{
+	"default": {},
+	// Summaries in Ubuntu kernel changelogs are upstream commit subjects,
+	// which are often longer than the line length limit.
+	"ubuntu-kernel": {
+		Rules: map[string]string{"line-length": "off"},
+	},
+	"strict-debian": {
+		Rules: map[string]string{
+			"distribution":        "error",
+			"indentation":         "error",
+			"line-length":         "error",
+			"trailing-whitespace": "error",
+		},
+	},
+}
+
+// resolveLintProfile returns the rule settings and the maximum line length
+// of the named profile, merged with the profiles it extends.
+func resolveLintProfile(name string, profiles map[string]lintProfile) (map[string]string, int, error) {
+	var chain []lintProfile
+	visited := make(map[string]bool)
+	for name != "" {
+		if visited[name] {
+			return nil, 0, fmt.Errorf("lint profile %s extends itself directly or indirectly", name)
+		}
+		visited[name] = true
+		p, ok := profiles[name]
+		if !ok {
+			p, ok = builtinLintProfiles[name]
+		}
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown lint profile: %s", name)
+		}
+		chain = append(chain, p)
+		name = p.Extends
+	}
+
+	rules := make(map[string]string)
+	maxLineLength := defaultMaxLineLength
+	for i := len(chain) - 1; i >= 0; i-- {
+		for rule, setting := range chain[i].Rules {
+			if _, _, err := parseRuleSetting(setting); err != nil {
+				return nil, 0, fmt.Errorf("rule %s: %s", rule, err)
+			}
+			rules[rule] = setting
+		}
+		if chain[i].MaxLineLength != 0 {
+			maxLineLength = chain[i].MaxLineLength
+		}
+	}
+	return rules, maxLineLength, nil
+}
+
+// parseRuleSetting parses "off", "warning" or "error".
+func parseRuleSetting(setting string) (sev severity, enabled bool, err error) {
+	switch setting {
+	case "off":
+		return 0, false, nil
+	case "warning":
+		return severityWarning, true, nil
+	case "error":
+		return severityError, true, nil
+	default:
+		return 0, false, fmt.Errorf("invalid rule setting %q, must be off, warning or error", setting)
+	}
+}
+
+// applyLintRules drops findings of disabled rules and overrides
+// severities of findings as configured in rules.
+func applyLintRules(findings []lintFinding, rules map[string]string) []lintFinding {
+	var applied []lintFinding
+	for _, f := range findings {
+		if setting, ok := rules[f.rule]; ok {
+			sev, enabled, _ := parseRuleSetting(setting)
+			if !enabled {
+				continue
+			}
+			f.severity = sev
+		}
+		applied = append(applied, f)
+	}
+	return applied
+}
+
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	filename := fs.String("file", "-", `changelog filename ("-" for stdin)`)
+	configFilename := fs.String("config", "", "config filename (default: $XDG_CONFIG_HOME/"+appName+"/config.json)")
+	profile := fs.String("profile", "", `lint profile, e.g. "default", "ubuntu-kernel" or "strict-debian" (default: the one in the config file or "default")`)
+	maxLineLength := fs.Int("max-line-length", defaultMaxLineLength, "maximum line length in characters (default: the one in the profile)")
+	fix := fs.Bool("fix", false, "repair the reported trailer spacing, trailing whitespace, indentation and long lines, and rewrite the file")
+	addErrorsFlag(fs)
+	fs.Parse(args)
+
+	if *fix && *filename == "-" {
+		return errors.New("-fix requires -file")
+	}
+	if *fix && isURL(*filename) {
+		return errors.New("-fix cannot rewrite a URL")
+	}
+
+	cfg, err := loadConfig(*configFilename)
+	if err != nil {
+		return err
+	}
+	if *profile == "" {
+		*profile = cfg.Lint.Profile
+	}
+	if *profile == "" {
+		*profile = "default"
+	}
+	rules, profileMaxLineLength, err := resolveLintProfile(*profile, cfg.Lint.Profiles)
+	if err != nil {
+		return err
+	}
+	if !isFlagSet(fs, "max-line-length") {
+		*maxLineLength = profileMaxLineLength
+	}
+
+	r, err := openInput(*filename)
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	if *fix {
+		raw, err := os.ReadFile(*filename)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(raw, content) {
+			return fmt.Errorf("-fix cannot rewrite %s, which is compressed or an archive", *filename)
+		}
+	}
+
+	findings, err := lintChangelog(bytes.NewReader(content), *maxLineLength)
+	if err != nil {
+		return err
+	}
+	findings = applyLintRules(findings, rules)
+
+	name := *filename
+	if name == "-" {
+		name = "<stdin>"
+	}
+
+	if *fix {
+		// Long lines are not wrapped if the profile turns line-length off.
+		width := *maxLineLength
+		if setting, ok := rules["line-length"]; ok {
+			if _, enabled, _ := parseRuleSetting(setting); !enabled {
+				width = 0
+			}
+		}
+		fixed := fixChangelog(content, findings, width)
+		if !bytes.Equal(fixed, content) {
+			if err := writeFileAtomic(*filename, fixed); err != nil {
+				return err
+			}
+			for _, f := range findings {
+				if fixableRules[f.rule] {
+					fmt.Printf("%s:%d: fixed: %s (%s)\n", name, f.line, f.message, f.rule)
+				}
+			}
+		}
+		// Report what is left with line numbers in the fixed file.
+		findings, err = lintChangelog(bytes.NewReader(fixed), *maxLineLength)
+		if err != nil {
+			return err
+		}
+		findings = applyLintRules(findings, rules)
+	}
+
+	errorCount := 0
+	for _, f := range findings {
+		fmt.Printf("%s:%d: %s: %s (%s)\n", name, f.line, f.severity, f.message, f.rule)
+		if f.severity == severityError {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("lint found %d error(s)", errorCount)
+	}
+	return nil
+}
+
+// fixChangelog returns content with the lines of findings of fixableRules
+// repaired and the other lines left as they are. Indentation is fixed like
+// formatChangelog does, and long lines are wrapped at width keeping their
+// indentation unless it is also fixed.
+func fixChangelog(content []byte, findings []lintFinding, width int) []byte {
+	fixes := make(map[int]map[string]bool)
+	for _, f := range findings {
+		if !fixableRules[f.rule] {
+			continue
+		}
+		if fixes[f.line] == nil {
+			fixes[f.line] = make(map[string]bool)
+		}
+		fixes[f.line][f.rule] = true
+	}
+
+	// The formatter follows changes and details to fix indentation in the
+	// same way as fmt.
+	f := &changelogFormatter{}
+	var b bytes.Buffer
+	for i, line := range strings.SplitAfter(string(content), "\n") {
+		if line == "" {
+			continue
+		}
+		text := strings.TrimSuffix(line, "\n")
+		eol := line[len(text):]
+		rules := fixes[i+1]
+		if rules["trailing-whitespace"] {
+			text = strings.TrimRight(text, " \t\r")
+		}
+		switch {
+		case text == "":
+		case text[0] != ' ' && text[0] != '\t':
+			if _, err := changelog.ParseEntryLine(text); err == nil {
+				f.state = fmtStateInEntry
+			}
+		case strings.HasPrefix(strings.TrimLeft(text, " \t"), "-- "):
+			if rules["trailer-spacing"] {
+				if trailer, ok := canonicalMaintainerLine(text); ok {
+					text = trailer
+				}
+			}
+		default:
+			f.width = 0
+			if rules["line-length"] {
+				f.width = width
+			}
+			formatted := f.formatBodyLine(text)
+			if rules["indentation"] {
+				text = strings.Join(formatted, "\n")
+			} else if rules["line-length"] {
+				text = strings.Join(wrapLine(text, width), "\n")
+			}
+		}
+		b.WriteString(text + eol)
+	}
+	return b.Bytes()
+}
+
+// wrapLine wraps line at width keeping its indentation, with the
+// continuation lines indented to the text after the bullet if any.
+func wrapLine(line string, width int) []string {
+	text := strings.TrimLeft(line, " \t")
+	restPrefix := line[:len(line)-len(text)]
+	if len(text) >= 2 && strings.IndexByte("*-+", text[0]) != -1 && text[1] == ' ' {
+		text = strings.TrimLeft(text[2:], " ")
+		restPrefix = strings.Repeat(" ", len(line)-len(text))
+	}
+	return changelog.WrapText(line[:len(line)-len(text)], restPrefix, text, width)
+}
+
+type linter struct {
+	maxLineLength int
+	findings      []lintFinding
+
+	prevVersion  string
+	versionLines map[string]int
+}
+
+func (l *linter) report(line int, sev severity, rule, format string, args ...any) {
+	l.findings = append(l.findings, lintFinding{
+		line:     line,
+		severity: sev,
+		rule:     rule,
+		message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// lintChangelog checks a changelog is formatted as described in
+// https://www.debian.org/doc/debian-policy/ch-source.html#debian-changelog-debian-changelog
+// and returns the findings in the order of line numbers.
+func lintChangelog(r io.Reader, maxLineLength int) ([]lintFinding, error) {
+	br := bufio.NewReader(r)
+	l := &linter{
+		maxLineLength: maxLineLength,
+		versionLines:  make(map[string]int),
+	}
+	inEntry := false
+	lineNo := 0
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		lineNo++
+		line = strings.TrimSuffix(line, "\n")
+		l.checkLine(lineNo, line)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		indented := line[0] == ' ' || line[0] == '\t'
+		if inEntry && !indented {
+			l.report(lineNo, severityError, "trailer", "missing trailer line before next entry")
+			inEntry = false
+		}
+		if !inEntry {
+			l.checkEntryLine(lineNo, line)
+			inEntry = true
+		} else if strings.HasPrefix(line, " --") {
+			l.checkMaintainerLine(lineNo, line)
+			inEntry = false
+		} else {
+			l.checkIndentation(lineNo, line)
+			l.checkCVEs(lineNo, line)
+		}
+	}
+	if inEntry {
+		l.report(lineNo, severityError, "trailer", "missing trailer line at end of file")
+	}
+	return l.findings, nil
+}
+
+func (l *linter) checkLine(lineNo int, line string) {
+	if strings.TrimRight(line, " \t\r") != line {
+		l.report(lineNo, severityWarning, "trailing-whitespace", "trailing whitespace")
+	}
+	if n := utf8.RuneCountInString(line); l.maxLineLength > 0 && n > l.maxLineLength {
+		l.report(lineNo, severityWarning, "line-length", "line is %d characters long, exceeds %d", n, l.maxLineLength)
+	}
+}
+
+func (l *linter) checkEntryLine(lineNo int, line string) {
+	if line[0] == ' ' || line[0] == '\t' {
+		l.report(lineNo, severityError, "heading", "expected entry heading line")
+		return
+	}
+	entry, err := changelog.ParseEntryLine(line)
+	if err != nil {
+		l.report(lineNo, severityError, "heading", "%s", err)
+		return
+	}
+	if err := changelog.ValidateVersion(entry.Version); err != nil {
+		l.report(lineNo, severityError, "version", "%s", err)
+	} else {
+		l.checkVersionOrder(lineNo, entry.Version)
+	}
+	for _, dist := range strings.Fields(entry.Distributions) {
+		if !isKnownDistribution(dist) {
+			l.report(lineNo, severityWarning, "distribution", "unknown distribution: %s", dist)
+		}
+	}
+}
+
+// checkVersionOrder checks entries appear in strictly decreasing version order,
+// since out of order or repeated versions usually come from merge mistakes.
+func (l *linter) checkVersionOrder(lineNo int, version string) {
+	if dupLineNo, ok := l.versionLines[version]; ok {
+		l.report(lineNo, severityError, "version-duplicate", "version %s already appeared at line %d", version, dupLineNo)
+	} else if l.prevVersion != "" && changelog.CompareVersions(version, l.prevVersion) >= 0 {
+		l.report(lineNo, severityError, "version-order", "version %s is not older than previous version %s", version, l.prevVersion)
+	}
+	if _, ok := l.versionLines[version]; !ok {
+		l.versionLines[version] = lineNo
+	}
+	l.prevVersion = version
+}
+
+func (l *linter) checkMaintainerLine(lineNo int, line string) {
+	var entry Entry
+	if err := changelog.ParseMaintainerLine(&entry, line); err != nil {
+		l.report(lineNo, severityError, "trailer", "%s", err)
+		return
+	}
+	_, afterEmail, _ := strings.Cut(line, ">")
+	if !strings.HasPrefix(afterEmail, "  ") || strings.HasPrefix(afterEmail, "   ") {
+		l.report(lineNo, severityError, "trailer-spacing", "email address and date must be separated by exactly two spaces")
+	}
+	if date := strings.TrimLeft(afterEmail, " "); entry.Date.Format(changelog.DateFormat) != date {
+		l.report(lineNo, severityWarning, "date-format", "date must be in the form of %q", changelog.DateFormat)
+	}
+}
+
+func (l *linter) checkIndentation(lineNo int, line string) {
+	body := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(body)]
+	if strings.Contains(indent, "\t") {
+		l.report(lineNo, severityWarning, "indentation", "tab in indentation")
+		return
+	}
+	switch {
+	case strings.HasPrefix(body, "* ") && len(indent) != len(changelog.ChangePrefix)-2:
+		l.report(lineNo, severityWarning, "indentation", "change bullet must be indented by %d spaces", len(changelog.ChangePrefix)-2)
+	case strings.HasPrefix(body, "- ") && len(indent) != len(changelog.DetailHeadPrefix)-2:
+		l.report(lineNo, severityWarning, "indentation", "detail bullet must be indented by %d spaces", len(changelog.DetailHeadPrefix)-2)
+	case len(indent) < len(changelog.ChangePrefix)-2:
+		l.report(lineNo, severityWarning, "indentation", "change lines must be indented by at least %d spaces", len(changelog.ChangePrefix)-2)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFixChangelogOnlyReportedLines(t *testing.T) {
+	content := []byte("linux (6.8.0-45.45)  noble; urgency=medium\n" +
+		"\n" +
+		"\n" +
+		"   * misindented change   \n" +
+		"    - detail\n" +
+		"\n" +
+		" -- A B <a@b.c> Fri, 30 Aug 2024 14:04:45 +0200\n")
+	want := "linux (6.8.0-45.45)  noble; urgency=medium\n" +
+		"\n" +
+		"\n" +
+		"  * misindented change   \n" +
+		"    - detail\n" +
+		"\n" +
+		" -- A B <a@b.c>  Fri, 30 Aug 2024 14:04:45 +0200\n"
+
+	findings, err := lintChangelog(bytes.NewReader(content), defaultMaxLineLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Trailing whitespace is not fixed if the rule is off.
+	findings = applyLintRules(findings, map[string]string{"trailing-whitespace": "off"})
+	if got := string(fixChangelog(content, findings, defaultMaxLineLength)); got != want {
+		t.Errorf("fixChangelog() =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+// lpBugsRegex matches Launchpad bug references like "LP: #2056789" or
+// "LP: #2056789, #2056790".
+var lpBugsRegex = regexp.MustCompile(`LP:\s*#\d+(?:\s*,\s*#\d+)*`)
+
+var lpBugNumberRegex = regexp.MustCompile(`#(\d+)`)
+
+// findLPBugs returns Launchpad bug numbers referenced in s.
+func findLPBugs(s string) []int {
+	var bugs []int
+	for _, ref := range lpBugsRegex.FindAllString(s, -1) {
+		for _, m := range lpBugNumberRegex.FindAllStringSubmatch(ref, -1) {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				bugs = append(bugs, n)
+			}
+		}
+	}
+	return bugs
+}
+
+// lpBugList collects unique Launchpad bug numbers with the version of
+// the oldest entry referencing each of them.
+type lpBugList struct {
+	versions map[int]string
+}
+
+func newLPBugList() *lpBugList {
+	return &lpBugList{versions: make(map[int]string)}
+}
+
+// addEntry adds bugs referenced in summaries and details of entry.
+func (l *lpBugList) addEntry(entry Entry) {
+	add := func(s string) {
+		for _, bug := range findLPBugs(s) {
+			if v, ok := l.versions[bug]; !ok || changelog.CompareVersions(entry.Version, v) < 0 {
+				l.versions[bug] = entry.Version
+			}
+		}
+	}
+	for _, change := range entry.Changes {
+		add(change.Summary)
+		for _, detail := range change.Details {
+			for _, line := range detail.Lines {
+				add(line)
+			}
+		}
+	}
+}
+
+// sorted returns the bug numbers in ascending order.
+func (l *lpBugList) sorted() []int {
+	bugs := make([]int, 0, len(l.versions))
+	for bug := range l.versions {
+		bugs = append(bugs, bug)
+	}
+	sort.Ints(bugs)
+	return bugs
+}
+
+// lpBugsWriter writes unique Launchpad bug numbers in matched entries.
+type lpBugsWriter struct {
+	w           io.Writer
+	withVersion bool
+	bugs        *lpBugList
+}
+
+func newLPBugsWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &lpBugsWriter{w: w, withVersion: opts.withVersion, bugs: newLPBugList()}
+}
+
+func (l *lpBugsWriter) WriteEntry(entry Entry) error {
+	l.bugs.addEntry(entry)
+	return nil
+}
+
+func (l *lpBugsWriter) Close() error {
+	for _, bug := range l.bugs.sorted() {
+		var err error
+		if l.withVersion {
+			_, err = fmt.Fprintf(l.w, "%d\t%s\n", bug, l.bugs.versions[bug])
+		} else {
+			_, err = fmt.Fprintln(l.w, bug)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lpBugsFlag is a flag.Value of -lp, which can be specified multiple
+// times. Bug numbers can be separated by commas and prefixed with "#".
+type lpBugsFlag []int
+
+func (f *lpBugsFlag) String() string {
+	s := make([]string, len(*f))
+	for i, bug := range *f {
+		s[i] = strconv.Itoa(bug)
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *lpBugsFlag) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		bug, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(s), "#"))
+		if err != nil || bug <= 0 {
+			return fmt.Errorf("invalid Launchpad bug number: %s", s)
+		}
+		*f = append(*f, bug)
+	}
+	return nil
+}
+
+// regexp returns the regular expression matching references to the bugs
+// like "LP: #2056789", including lists like "LP: #2056788, #2056789".
+func (f lpBugsFlag) regexp() *regexp.Regexp {
+	s := make([]string, len(f))
+	for i, bug := range f {
+		s[i] = strconv.Itoa(bug)
+	}
+	return regexp.MustCompile(`LP:\s*(?:#\d+\s*,\s*)*#(?:` + strings.Join(s, "|") + `)\b`)
+}
@@ -1,77 +1,323 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+// Entry, Change and Detail are the types of the changelog package, which
+// is used by the subcommands and the output formats.
+type (
+	Entry  = changelog.Entry
+	Change = changelog.Change
+	Detail = changelog.Detail
 )
 
-// https://manpages.debian.org/testing/dpkg-dev/deb-changelog.5.en.html
-type Entry struct {
-	Package        string    `json:"package"`
-	Version        string    `json:"version"`
-	Distributions  string    `json:"distributions"`
-	Metadata       string    `json:"metadata"`
-	MaintainerName string    `json:"maintainer_name"`
-	EmailAddress   string    `json:"email_address"`
-	Date           time.Time `json:"date"`
-	Changes        []Change  `json:"changes"`
+// isFlagSet returns whether the flag is specified in the command line.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
-type Change struct {
-	Summary string   `json:"summary"`
-	Details []Detail `json:"details"`
+// latestFlag is a flag.Value of -latest, which is 1 if given without a
+// value like "-latest", or the number given like "-latest=3".
+type latestFlag int
+
+func (f *latestFlag) String() string {
+	return strconv.Itoa(int(*f))
 }
 
-type Detail struct {
-	Lines []string `json:"lines"`
+func (f *latestFlag) Set(value string) error {
+	switch value {
+	case "true":
+		*f = 1
+		return nil
+	case "false":
+		*f = 0
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("must be a non-negative number: %s", value)
+	}
+	*f = latestFlag(n)
+	return nil
 }
 
-const (
-	changePrefix         = "  * "
-	detailHeadPrefix     = "    - "
-	detailTailPrefix     = "      "
-	maintainerLinePrefix = " -- "
-)
+// IsBoolFlag makes "-latest" valid without a value.
+func (f *latestFlag) IsBoolFlag() bool {
+	return true
+}
 
-type parseState int
+// stringsFlag is a flag.Value which can be specified multiple times.
+type stringsFlag []string
 
-const (
-	parseStateInitial parseState = iota
-	parseStateInEntry
-	parseStateInChange
-	parseStateInDetail
-)
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+type subcommand struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+// subcommands are run when the first argument is the name of one of them.
+// Otherwise the changelog is filtered as specified by flags.
+var subcommands = []subcommand{
+	{name: "lint", description: "check changelog policy compliance", run: runLint},
+	{name: "fmt", description: "reformat changelog in the canonical format", run: runFmt},
+	{name: "new-entry", description: "prepend a new entry to changelog", run: runNewEntry},
+	{name: "merge-files", description: "three-way merge changelogs", run: runMergeFiles},
+	{name: "verify-cves", description: "check referenced CVE IDs are valid", run: runVerifyCVEs},
+	{name: "convert", description: "convert entries between JSON and changelog formats", run: runConvert},
+	{name: "schema", description: "print the JSON Schema of the JSON output", run: runSchema},
+}
 
 func main() {
+	if len(os.Args) > 1 {
+		for _, cmd := range subcommands {
+			if os.Args[1] == cmd.name {
+				if err := cmd.run(os.Args[2:]); err != nil {
+					exitWithError(err)
+				}
+				return
+			}
+		}
+	}
+
 	flag.Usage = func() {
 		basename := filepath.Base(os.Args[0])
 		output := flag.CommandLine.Output()
 		fmt.Fprintf(output, "%s - filter for Ubuntu Linux kernel changelog\n\n", basename)
 		fmt.Fprintf(output, "Usage of %s:\n", basename)
 		flag.PrintDefaults()
+		fmt.Fprintf(output, "\nSubcommands (run \"%s <subcommand> -h\" for help):\n", basename)
+		for _, cmd := range subcommands {
+			fmt.Fprintf(output, "  %-12s %s\n", cmd.name, cmd.description)
+		}
 	}
 
-	filename := flag.String("file", "-", `changelog filename ("-" for stdin)`)
-	filter := flag.String("filter", ".", "regular expression to be matched for change summary and details.\nSee https://pkg.go.dev/regexp/syntax for syntax.")
+	var opts options
+	flag.Var((*stringsFlag)(&opts.filenames), "file", "changelog filename (\"-\" for stdin), or an http:// or https:// URL to fetch it from.\nCan be specified multiple times, and filenames can also be given as arguments (default \"-\")")
+	flag.BoolVar(&opts.recursive, "recursive", false, "read all changelog.Debian* files under directories given as -file, like /usr/share/doc,\nreporting the package owning each of them")
+	flag.StringVar(&opts.gitRepo, "git", "", "read the changelog at -path in each tag of this git repository, or each commit of -git-range,\nskipping entries of versions already read")
+	flag.StringVar(&opts.gitPath, "path", "debian/changelog", "path of the changelog in the -git repository, like debian.master/changelog for Ubuntu kernel trees")
+	flag.StringVar(&opts.gitRange, "git-range", "", `read the changelog at each commit changing -path in this range like "A..B" instead of each tag`)
+	flag.DurationVar(&httpClient.Timeout, "fetch-timeout", defaultFetchTimeout, "timeout to fetch changelogs over HTTP including reading the body")
+	flag.StringVar(&httpCacheDir, "cache-dir", "", "directory to cache changelogs fetched over HTTP in, revalidated with ETag and Last-Modified\n(default: $XDG_CACHE_HOME/"+appName+")")
+	noCache := flag.Bool("no-cache", false, "always download changelogs over HTTP without the cache")
+	flag.StringVar(&opts.source, "source", "", "name of a source command defined in the config file to read the changelog from instead of -file")
+	flag.StringVar(&opts.installed, "installed", "", `read the changelog of this installed package under /usr/share/doc, or "running" for the image package of the running kernel`)
+	flag.StringVar(&opts.apt, "apt", "", `fetch the changelog of this package with "apt-get changelog", using the proxy and Acquire settings
+in the apt configuration. "package=version" and "package/release" are also accepted`)
+	flag.StringVar(&opts.ppa, "ppa", "", `fetch the changelog from the Launchpad PPA in the form of "team/name" instead of -file`)
+	flag.StringVar(&opts.pkg, "package", "", "source package name for -source, -ppa, -published, -package-version or -fetch-series (default \"linux\" for -ppa).\nOtherwise, regular expression to select entries of matching source packages, like \"^linux\" with -recursive")
+	flag.StringVar(&opts.pkgVersion, "package-version", "", "fetch the changelog of this version of -package from the Ubuntu archive or the mirror in the config file")
+	flag.BoolVar(&opts.published, "published", false, "fetch the changelogs of all versions of -package ever published in the Ubuntu archive, newest first,\nskipping entries of versions already read. The versions are looked up with the Launchpad API\nand can be limited with -series and -pocket")
+	flag.StringVar(&opts.pocket, "pocket", "", `pocket for -published, "Release", "Security", "Updates", "Proposed" or "Backports" (default: all)`)
+	flag.StringVar(&opts.component, "component", "main", "archive component of -package for -package-version")
+	flag.StringVar(&opts.series, "series", "", "series name for -source, -ppa or -published. Otherwise, select entries whose distributions are in\nthe series separated by commas like \"jammy,noble\", ignoring pockets like -security")
+	flag.StringVar(&opts.fetchSeries, "fetch-series", "", "fetch the changelog of the newest version of -package published in this series from changelogs.ubuntu.com,\nor in this suite like \"stable\" or \"unstable\" from metadata.ftp-master.debian.org with -archive debian")
+	flag.StringVar(&opts.archive, "archive", "ubuntu", `archive to fetch changelogs from with -package-version and -fetch-series, "ubuntu" or "debian".
+The mirror in the config file is used for -package-version if specified`)
+	flag.Var((*stringsFlag)(&opts.filters), "filter", "regular expression to be matched for change summary and details.\nCan be specified multiple times to be combined with -match-mode.\nSee https://pkg.go.dev/regexp/syntax for syntax. (default \".\")")
+	flag.Var((*stringsFlag)(&opts.globs), "glob", "shell-style wildcard pattern like \"*ext4*\" to be matched for whole lines of change summary and details,\nlike -filter. \"*\", \"?\" and \"[...]\" are supported. Can be specified multiple times")
+	flag.Var((*stringsFlag)(&opts.filterFiles), "filter-file", "read regular expressions from this file, one per line, skipping empty lines and lines starting with #.\nA change matches if any of them matches, and the file is combined with -filter like another -filter")
+	flag.BoolVar(&opts.matchHeader, "match-header", false, "also match -filter and -exclude against the heading line of entries with the package, version,\ndistributions and metadata, and the maintainer name and email, selecting all changes of matched entries")
+	flag.BoolVar(&opts.entryMode, "entry-mode", false, "write all changes and details of entries with any matched change, instead of only matched ones")
+	flag.Var((*stringsFlag)(&opts.excludes), "exclude", "regular expression to remove changes matching it in the summary or details after the other filters.\nCan be specified multiple times")
+	flag.StringVar(&opts.query, "query", "", `select changes satisfying the expression also, like '(cve AND netfilter) OR summary~"stable update"'.
+See README for fields and operators`)
+	flag.BoolVar(&opts.ignoreCase, "i", false, "same as -ignore-case")
+	flag.BoolVar(&opts.ignoreCase, "ignore-case", false, "match -filter, -exclude, -cve and -lp ignoring case")
+	flag.BoolVar(&opts.fixedStrings, "F", false, "same as -fixed-strings")
+	flag.BoolVar(&opts.fixedStrings, "fixed-strings", false, "match -filter and -exclude as literal strings instead of regular expressions")
+	flag.StringVar(&opts.matchMode, "match-mode", matchModeAny, fmt.Sprintf("%q to select changes matching any of -filter, -cve and -lp, or %q to select changes matching all of them", matchModeAny, matchModeAll))
+	flag.Var(&opts.cve, "cve", "match CVE IDs like -filter, or only the CVE IDs given like \"-cve CVE-2024-1234,CVE-2024-5678\".\nCan be specified multiple times")
+	flag.Var(&opts.lpBugs, "lp", "match changes referencing these Launchpad bugs like \"LP: #2056789\" like -filter.\nCan be specified multiple times, and bug numbers can also be separated by commas")
+	flag.StringVar(&opts.maintainer, "maintainer", "", `regular expression to be matched for "name <email>" of the maintainer of entries`)
+	flag.BoolVar(&opts.securityOnly, "security-only", false, "select entries uploaded to -security pockets, or with changes referencing CVE IDs or USNs")
+	flag.StringVar(&opts.sinceVersion, "since-version", "", "drop entries of this version or older, to show what changes by upgrading from it")
+	flag.BoolVar(&opts.sinceInstalled, "since-installed", false, "drop entries of the installed version of their source package or older, read with dpkg-query")
+	flag.StringVar(&opts.urgency, "urgency", "", `select entries with these urgencies separated by commas like "high,critical"`)
+	flag.Var((*stringsFlag)(&opts.subsystems), "subsystem", fmt.Sprintf("match changes mentioning kernel source paths like \"drivers/net,fs/btrfs\" like -filter, or subsystems\n%s. Can be specified multiple times", strings.Join(subsystemNames(), ", ")))
+	flag.Var((*stringsFlag)(&opts.upstream), "upstream", "match \"v6.8.7 upstream stable release\" changes of the Ubuntu kernel updated to these upstream stable\nversions separated by commas with all their details, like -filter. Can be specified multiple times")
+	flag.IntVar(&opts.maxCount, "max-count", 0, "stop reading each input after this number of matched changes (0 for unlimited)")
+	flag.IntVar(&opts.maxCountTotal, "max-count-total", 0, "stop reading all inputs after this number of matched changes in total (0 for unlimited)")
+	flag.StringVar(&opts.timezone, "tz", "", `convert entry dates to this timezone, "UTC", "Local" or "Area/City" (default: keep as is)`)
+	flag.StringVar(&opts.color, "color", "auto", `color text output, "auto" (when stdout is a terminal and $NO_COLOR is not set), "always" or "never"`)
+	flag.BoolVar(&opts.highlight, "highlight", true, "color substrings matched with filters in the colored text output")
+	flag.BoolVar(&opts.showAge, "age", false, `show age of entries like "2 weeks ago" after dates`)
+	groupBy := flag.String("group-by", "", "group matched entries by \"cve\", printing a section for each CVE ID with the entries mentioning it\nto stdout instead of the -format output")
+	listLPBugs := flag.Bool("list-lp-bugs", false, "print unique Launchpad bug numbers in matched changes to stdout instead of the -format output")
+	var outputs stringsFlag
+	flag.Var(&outputs, "output", fmt.Sprintf("write output in format to filename (\"-\" for stdout), in the form of format=filename,\nor to filename in the -format format instead of stdout. Files are replaced only after\nall output is written successfully. Can be specified multiple times. Formats: %s and templates\n(default: text=- if stdout is a terminal, ndjson=- otherwise)", strings.Join(outputFormatNames(), ", ")))
+	outputSQLite := flag.String("output-sqlite", "", "write entries, changes, details and CVEs to tables in this SQLite database file.\nThe sqlite3 command is used to write the database (same as -output sql=- | sqlite3 file)")
+	outputParquet := flag.String("output-parquet", "", "write a row for each detail of matched changes to this Parquet file (same as -output parquet=file)")
+	flag.Var((*byteSizeFlag)(&opts.maxMemory), "max-memory", "with the atom, cve-groups, html, parquet and xlsx formats, which write output after all entries,\nmove the data kept for entries to temporary files when it exceeds this size like \"256M\" (0 for unlimited).\nTemplate formats and -list-lp-bugs keep their data in memory")
+	flag.BoolVar(&opts.withVersion, "with-version", false, "with -list-lp-bugs, also print the version of the oldest entry referencing each bug")
+	flag.BoolVar(&opts.failOnWarnings, "fail-on-warnings", false, fmt.Sprintf("report unrecognized lines and exit with status %d if any, or if any input is skipped\nwith -recursive, -published or -git", exitCodeParseWarnings))
+	flag.BoolVar(&opts.strict, "strict", false, "fail at the first line which does not fit the changelog format, instead of keeping\nunrecognized lines like \"[ John Doe ]\" with the current change or entry")
+	flag.IntVar(&opts.limit, "limit", 0, "stop reading all inputs after this number of matched entries are written (0 for unlimited)")
+	flag.IntVar(&opts.skip, "skip", 0, "skip this number of matched entries before writing, to page through them with -limit")
+	var latest latestFlag
+	flag.Var(&latest, "latest", "read only the newest entry of each input, or the newest N entries with -latest=N\n(same as -max-parse-entries 1 or N)")
+	flag.IntVar(&opts.maxParseEntries, "max-parse-entries", 0, "stop reading input after this number of entries are parsed, regardless of matches (0 for unlimited)")
+	oneline := flag.Bool("oneline", false, "print a line for each entry with the version, the date, the number of matched changes\nand the first matched summary, written to stdout instead of the -format output")
+	flag.BoolVar(&opts.print0, "print0", false, "with the text or oneline format, terminate each entry with a NUL character instead of newlines for xargs -0")
+	raw := flag.Bool("raw", false, "write the original text of entries with matched changes byte for byte (same as -format raw)")
+	format := flag.String("format", "", "output format for stdout, one of built-in formats or templates\n(default: text if stdout is a terminal, ndjson otherwise)")
+	entryTemplate := flag.String("template", "", "text/template executed for each matched entry with the entry as data, written to stdout\nunless -output template=filename is specified")
+	entryTemplateFile := flag.String("template-file", "", "read the template for -template from this file")
+	configFilename := flag.String("config", "", "config filename (default: $XDG_CONFIG_HOME/"+appName+"/config.json)")
 	showVersion := flag.Bool("version", false, "show version and exit")
-	flag.Parse()
+	addErrorsFlag(flag.CommandLine)
+	flag.CommandLine.Parse(joinCVEFlagArgs(os.Args[1:]))
+	opts.filenames = append(opts.filenames, flag.Args()...)
 
 	if *showVersion {
 		fmt.Println(Version())
 		return
 	}
+	if err := validateColorMode(opts.color); err != nil {
+		exitWithError(err)
+	}
+	if latest > 0 && (opts.maxParseEntries == 0 || int(latest) < opts.maxParseEntries) {
+		opts.maxParseEntries = int(latest)
+	}
+	if *noCache {
+		httpCacheDir = ""
+	} else if httpCacheDir == "" {
+		// The cache is only an optimization, so fetch without it if
+		// there is no cache directory.
+		httpCacheDir, _ = defaultCacheDir()
+	}
 
-	if err := run(*filename, *filter); err != nil {
-		log.Fatal(err)
+	cfg, err := loadConfig(*configFilename)
+	if err != nil {
+		exitWithError(err)
+	}
+	if err := registerTemplateFormats(cfg.TemplatesDir); err != nil {
+		exitWithError(err)
+	}
+	opts.sources = cfg.Sources
+	opts.launchpad = cfg.Launchpad
+	opts.mirror = cfg.Mirror
+	switch opts.archive {
+	case "ubuntu":
+	case "debian":
+		if opts.mirror == nil {
+			opts.mirror = &mirrorConfig{URL: debianChangelogURL}
+		}
+	default:
+		exitWithError(fmt.Errorf("unknown archive: %s", opts.archive))
+	}
+
+	if *entryTemplateFile != "" {
+		data, err := os.ReadFile(*entryTemplateFile)
+		if err != nil {
+			exitWithError(err)
+		}
+		*entryTemplate = string(data)
+	}
+	if *entryTemplate != "" {
+		if err := registerEntryTemplate(*entryTemplate); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	// Humans read text on terminals, while programs in pipelines
+	// are easier to feed with one JSON object per line.
+	stdoutFormat := *format
+	if stdoutFormat == "" {
+		stdoutFormat = "text"
+		if !isTerminal(os.Stdout) {
+			stdoutFormat = "ndjson"
+		}
+	}
+	// A filename without a format is written in the same format as
+	// stdout would be, instead of stdout.
+	stdoutReplaced := false
+	for i, output := range outputs {
+		if !strings.Contains(output, "=") {
+			outputs[i] = stdoutFormat + "=" + output
+			stdoutReplaced = true
+		}
+	}
+	// Flags like -oneline select the format of stdout instead of -format,
+	// rather than adding another target writing to stdout.
+	stdoutShorthand, stdoutShorthandFlag := "", ""
+	selectStdoutFormat := func(flagName, format string) {
+		if stdoutShorthand != "" && stdoutShorthand != format {
+			exitWithError(fmt.Errorf("-%s cannot be used with -%s", flagName, stdoutShorthandFlag))
+		}
+		stdoutShorthand, stdoutShorthandFlag = format, flagName
+	}
+	if *oneline {
+		selectStdoutFormat("oneline", "oneline")
+	}
+	switch *groupBy {
+	case "":
+	case "cve":
+		selectStdoutFormat("group-by", "cve-groups")
+	default:
+		exitWithError(fmt.Errorf(`invalid -group-by %q, must be "cve"`, *groupBy))
+	}
+	if *listLPBugs {
+		selectStdoutFormat("list-lp-bugs", "lp-bugs")
+	}
+	if stdoutShorthand != "" {
+		outputs = append(outputs, stdoutShorthand+"=-")
+	} else if *format != "" && !stdoutReplaced {
+		outputs = append(outputs, *format+"=-")
+	}
+	if *raw {
+		outputs = append(outputs, "raw=-")
+	}
+	for _, output := range outputs {
+		target, err := parseOutputTarget(output)
+		if err != nil {
+			exitWithError(err)
+		}
+		opts.outputs = append(opts.outputs, target)
+	}
+	if *entryTemplate != "" && !hasOutputFormat(opts.outputs, "template") {
+		opts.outputs = append(opts.outputs, outputTarget{format: "template", filename: "-"})
+	}
+	if *outputParquet != "" {
+		opts.outputs = append(opts.outputs, outputTarget{format: "parquet", filename: *outputParquet})
+	}
+	if *outputSQLite != "" {
+		opts.outputs = append(opts.outputs, outputTarget{format: "sql", filename: *outputSQLite, command: []string{"sqlite3", *outputSQLite}})
+	}
+	if len(opts.outputs) == 0 {
+		opts.outputs = []outputTarget{{format: stdoutFormat, filename: "-"}}
+	}
+
+	if err := run(opts); err != nil {
+		exitWithError(err)
 	}
 }
 
@@ -84,252 +330,541 @@ func Version() string {
 	return info.Main.Version
 }
 
-func run(filename, filter string) error {
-	filterRE, err := regexp.Compile(filter)
+type options struct {
+	filenames       []string
+	recursive       bool
+	gitRepo         string
+	gitPath         string
+	gitRange        string
+	source          string
+	sources         map[string]sourceConfig
+	ppa             string
+	installed       string
+	apt             string
+	launchpad       *launchpadConfig
+	pkgVersion      string
+	component       string
+	mirror          *mirrorConfig
+	pkg             string
+	series          string
+	fetchSeries     string
+	archive         string
+	pocket          string
+	published       bool
+	filters         []string
+	matchMode       string
+	filterFiles     []string
+	globs           []string
+	excludes        []string
+	ignoreCase      bool
+	fixedStrings    bool
+	query           string
+	entryMode       bool
+	matchHeader     bool
+	cve             cveFlag
+	lpBugs          lpBugsFlag
+	subsystems      []string
+	upstream        []string
+	maintainer      string
+	urgency         string
+	securityOnly    bool
+	sinceVersion    string
+	sinceInstalled  bool
+	maxCount        int
+	maxCountTotal   int
+	maxParseEntries int
+	limit           int
+	skip            int
+	timezone        string
+	showAge         bool
+	maxMemory       int64
+	highlight       bool
+	color           string
+	print0          bool
+	outputs         []outputTarget
+	withVersion     bool
+	failOnWarnings  bool
+	strict          bool
+}
+
+// exitCodeParseWarnings is the exit status with -fail-on-warnings when
+// unrecognized lines were found while parsing, even though filtering
+// succeeded.
+const exitCodeParseWarnings = 3
+
+var errParseWarnings = errors.New("parse warnings occurred")
+
+func run(opts options) error {
+	filter, err := newOptionsFilter(opts)
 	if err != nil {
 		return err
 	}
-
-	var entries []Entry
-	if filename == "-" {
-		entries, err = parseChangelog(os.Stdin)
+	// -series selects the changelog to fetch if -source, -ppa or
+	// -published is specified, and entries otherwise.
+	var series []string
+	if opts.series != "" && opts.source == "" && opts.ppa == "" && !opts.published {
+		series = strings.Split(opts.series, ",")
+	}
+	// -package selects entries only if it does not select the changelog
+	// to fetch.
+	var packageRE *regexp.Regexp
+	if opts.pkg != "" && opts.source == "" && opts.ppa == "" && !opts.published && opts.pkgVersion == "" && opts.fetchSeries == "" {
+		packageRE, err = regexp.Compile(opts.pkg)
 		if err != nil {
 			return err
 		}
-	} else {
-		entries, err = parseChangelogFile(filename)
+	}
+	if opts.sinceVersion != "" {
+		if err := changelog.ValidateVersion(opts.sinceVersion); err != nil {
+			return err
+		}
+	}
+	var installedVersions map[string]string
+	if opts.sinceInstalled {
+		installedVersions, err = installedSourceVersions()
 		if err != nil {
 			return err
 		}
 	}
-
-	filtered, err := filterEntries(entries, filterRE)
-	if err != nil {
-		return err
+	var urgencies []string
+	if opts.urgency != "" {
+		urgencies = strings.Split(strings.ToLower(opts.urgency), ",")
+	}
+	var maintainerRE *regexp.Regexp
+	if opts.maintainer != "" {
+		maintainerRE, err = regexp.Compile(opts.maintainer)
+		if err != nil {
+			return err
+		}
 	}
 
-	for i, entry := range filtered {
-		if i > 0 {
-			fmt.Println()
+	var loc *time.Location
+	if opts.timezone != "" {
+		loc, err = time.LoadLocation(opts.timezone)
+		if err != nil {
+			return err
 		}
-		fmt.Printf("%s\n", entry.String())
 	}
-	return nil
-}
 
-func parseChangelogFile(filename string) ([]Entry, error) {
-	file, err := os.Open(filename)
+	inputs, err := openers(opts)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer file.Close()
 
-	return parseChangelog(bufio.NewReader(file))
-}
-
-func parseChangelog(r io.Reader) ([]Entry, error) {
-	br, ok := r.(*bufio.Reader)
-	if !ok {
-		br = bufio.NewReader(r)
+	var highlights []*regexp.Regexp
+	if opts.highlight {
+		highlights = filter.HighlightPatterns()
 	}
-	var entries []Entry
-	var entry *Entry
-	var change *Change
-	var detail *Detail
-	state := parseStateInitial
-
-	processChangeLine := func(line string) {
-		entry.Changes = append(entry.Changes, Change{
-			Summary: line[len(changePrefix):],
-		})
-		change = &entry.Changes[len(entry.Changes)-1]
-		state = parseStateInChange
+	out, err := openOutputs(opts.outputs, outputOptions{
+		showAge:     opts.showAge,
+		withVersion: opts.withVersion,
+		color:       opts.color,
+		print0:      opts.print0,
+		highlights:  highlights,
+		maxMemory:   opts.maxMemory,
+	})
+	if err != nil {
+		return err
 	}
+	defer out.Abort()
+
+	warningCount := 0
+	totalMatchCount := 0
+	// -limit and -skip are the numbers of matched entries in all inputs.
+	entryCount := 0
+	skippedEntryCount := 0
+	// seen has the package and the version of entries already read from
+	// the inputs with skipSeen.
+	seen := make(map[string]bool)
+	for _, in := range inputs {
+		var warn func(lineNo int, message string)
+		if opts.failOnWarnings {
+			warn = func(lineNo int, message string) {
+				warnAt(in.name, lineNo, "unrecognized_line", message)
+				warningCount++
+			}
+		}
 
-	processDetailHeadLine := func(line string) {
-		change.Details = append(change.Details, Detail{
-			Lines: []string{line[len(detailHeadPrefix):]},
+		// A changelog found with -recursive or -git which cannot be read
+		// is reported as a warning so that the other changelogs are read,
+		// which fails only with -fail-on-warnings.
+		skip := func(err error) error {
+			if !in.skipErrors {
+				return err
+			}
+			r := newErrorReport("warning", err)
+			if r.File == "" {
+				r.File = in.name
+			}
+			writeErrorReport(r)
+			if opts.failOnWarnings {
+				warningCount++
+			}
+			return nil
+		}
+
+		r, err := in.open()
+		if err != nil {
+			if err := skip(err); err != nil {
+				return err
+			}
+			continue
+		}
+		// -max-count and -max-parse-entries are for each input, and
+		// -max-count-total is for all inputs.
+		matchCount := 0
+		parsedCount := 0
+		var writeErr error
+		parser := &changelog.Parser{Strict: opts.strict, Warn: warn}
+		err = parser.Parse(r, func(entry Entry) error {
+			parsedCount++
+			if in.skipSeen {
+				key := entry.Package + " " + entry.Version
+				if seen[key] {
+					return stopIfReached(parsedCount, opts.maxParseEntries)
+				}
+				seen[key] = true
+			}
+			if opts.sinceVersion != "" && changelog.CompareVersions(entry.Version, opts.sinceVersion) <= 0 {
+				return stopIfReached(parsedCount, opts.maxParseEntries)
+			}
+			if v, ok := installedVersions[entry.Package]; ok && changelog.CompareVersions(entry.Version, v) <= 0 {
+				return stopIfReached(parsedCount, opts.maxParseEntries)
+			}
+			if packageRE != nil && !packageRE.MatchString(entry.Package) {
+				return stopIfReached(parsedCount, opts.maxParseEntries)
+			}
+			if series != nil && !inSeries(entry.Distributions, series) {
+				return stopIfReached(parsedCount, opts.maxParseEntries)
+			}
+			if urgencies != nil && !containsString(urgencies, entry.Urgency) {
+				return stopIfReached(parsedCount, opts.maxParseEntries)
+			}
+			if opts.securityOnly && !isSecurityEntry(&entry) {
+				return stopIfReached(parsedCount, opts.maxParseEntries)
+			}
+			if maintainerRE != nil && !maintainerRE.MatchString(entry.MaintainerName+" <"+entry.EmailAddress+">") {
+				return stopIfReached(parsedCount, opts.maxParseEntries)
+			}
+			filtered, ok := changelog.FilterEntry(entry, filter)
+			if !ok {
+				return stopIfReached(parsedCount, opts.maxParseEntries)
+			}
+			if opts.entryMode {
+				filtered.Changes = entry.Changes
+			}
+			if skippedEntryCount < opts.skip {
+				skippedEntryCount++
+				return stopIfReached(parsedCount, opts.maxParseEntries)
+			}
+			if loc != nil {
+				filtered.Date = filtered.Date.In(loc)
+			}
+			if len(inputs) > 1 || in.binaryPackage != "" {
+				filtered.Filename = in.name
+			}
+			filtered.BinaryPackage = in.binaryPackage
+			if opts.maxCount > 0 && matchCount+len(filtered.Changes) > opts.maxCount {
+				filtered.Changes = filtered.Changes[:opts.maxCount-matchCount]
+			}
+			if opts.maxCountTotal > 0 && totalMatchCount+len(filtered.Changes) > opts.maxCountTotal {
+				filtered.Changes = filtered.Changes[:opts.maxCountTotal-totalMatchCount]
+			}
+			if err := out.WriteEntry(filtered); err != nil {
+				writeErr = err
+				return err
+			}
+			matchCount += len(filtered.Changes)
+			totalMatchCount += len(filtered.Changes)
+			entryCount++
+			if err := stopIfReached(entryCount, opts.limit); err != nil {
+				return err
+			}
+			if err := stopIfReached(matchCount, opts.maxCount); err != nil {
+				return err
+			}
+			if err := stopIfReached(totalMatchCount, opts.maxCountTotal); err != nil {
+				return err
+			}
+			return stopIfReached(parsedCount, opts.maxParseEntries)
 		})
-		detail = &change.Details[len(change.Details)-1]
-		state = parseStateInDetail
+		if perr, ok := err.(*changelog.ParseError); ok {
+			perr.Filename = in.name
+		}
+		if err == nil {
+			// Report a failure of the source command, which is only known
+			// after it exits.
+			err = r.Close()
+		} else {
+			r.Close()
+			if err == changelog.ErrStopParsing {
+				err = nil
+			}
+		}
+		if writeErr != nil {
+			return writeErr
+		}
+		if err != nil {
+			if err := skip(err); err != nil {
+				return err
+			}
+		}
+		if stopIfReached(totalMatchCount, opts.maxCountTotal) != nil || stopIfReached(entryCount, opts.limit) != nil {
+			break
+		}
 	}
 
-	processDetailTailLine := func(line string) {
-		detail.Lines = append(detail.Lines, line[len(detailTailPrefix):])
+	if err := out.Close(); err != nil {
+		return err
 	}
-
-	processMaintainerLine := func(line string) error {
-		if err := parseMaintainerLine(entry, line); err != nil {
-			return err
-		}
-		entries = append(entries, *entry)
-		state = parseStateInitial
-		return nil
+	if warningCount > 0 {
+		return fmt.Errorf("%w: %d warning(s)", errParseWarnings, warningCount)
 	}
+	return nil
+}
 
-	for {
-		line, err := br.ReadString('\n')
+// input is a changelog to read, with the name used in warnings and
+// output. binaryPackage is the package owning the changelog found with
+// -recursive.
+type input struct {
+	name          string
+	binaryPackage string
+	open          func() (io.ReadCloser, error)
+	// skipErrors makes errors reading the input warnings.
+	skipErrors bool
+	// skipSeen skips entries of versions already read from another input.
+	skipSeen bool
+}
+
+// openers returns the inputs specified by options. The changelog is
+// fetched or read from a command with -source, -installed, -apt, -ppa,
+// -published, -package-version, -fetch-series or -git in this order, or read from files
+// otherwise.
+func openers(opts options) ([]input, error) {
+	pkg := opts.pkg
+	switch {
+	case opts.source != "":
+		return []input{{name: opts.source, open: func() (io.ReadCloser, error) {
+			return openSource(opts.sources, opts.source, pkg, opts.series)
+		}}}, nil
+	case opts.installed != "":
+		return []input{{name: opts.installed, open: func() (io.ReadCloser, error) {
+			return openInstalledChangelog(opts.installed)
+		}}}, nil
+	case opts.apt != "":
+		return []input{{name: opts.apt, open: func() (io.ReadCloser, error) {
+			return openAptChangelog(opts.apt)
+		}}}, nil
+	case opts.archive != "ubuntu" && (opts.ppa != "" || opts.published):
+		return nil, errors.New("-ppa and -published are only for the Ubuntu archive")
+	case opts.ppa != "":
+		if pkg == "" {
+			pkg = "linux"
+		}
+		return []input{{name: "ppa:" + opts.ppa, open: func() (io.ReadCloser, error) {
+			return openPPAChangelog(opts.launchpad, opts.ppa, pkg, opts.series)
+		}}}, nil
+	case opts.published:
+		if pkg == "" {
+			return nil, errors.New("-package must be specified with -published")
+		}
+		pubs, err := findSourceVersions(opts.launchpad, pkg, opts.series, opts.pocket)
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
 			return nil, err
 		}
-		line = strings.TrimRight(line, "\n")
-		if len(line) == 0 {
-			continue
+		if len(pubs) == 0 {
+			return nil, fmt.Errorf("no published source of %s", pkg)
 		}
-
-		switch state {
-		case parseStateInitial:
-			var err error
-			entry, err = parseEntryLine(line)
-			if err != nil {
-				return nil, err
+		var inputs []input
+		for _, pub := range pubs {
+			pub := pub
+			component := pub.ComponentName
+			if component == "" {
+				component = "main"
 			}
-			state = parseStateInEntry
-		case parseStateInEntry:
-			if strings.HasPrefix(line, changePrefix) {
-				processChangeLine(line)
-			} else if strings.HasPrefix(line, maintainerLinePrefix) {
-				if err := processMaintainerLine(line); err != nil {
-					return nil, err
-				}
+			inputs = append(inputs, input{name: pkg + "_" + pub.SourcePackageVersion, skipErrors: true, skipSeen: true, open: func() (io.ReadCloser, error) {
+				return openPackageChangelog(opts.mirror, component, pkg, pub.SourcePackageVersion)
+			}})
+		}
+		return inputs, nil
+	case opts.pkgVersion != "":
+		if pkg == "" {
+			return nil, errors.New("-package must be specified with -package-version")
+		}
+		return []input{{name: pkg + "_" + opts.pkgVersion, open: func() (io.ReadCloser, error) {
+			return openPackageChangelog(opts.mirror, opts.component, pkg, opts.pkgVersion)
+		}}}, nil
+	case opts.fetchSeries != "":
+		if pkg == "" {
+			return nil, errors.New("-package must be specified with -fetch-series")
+		}
+		return []input{{name: pkg + "/" + opts.fetchSeries, open: func() (io.ReadCloser, error) {
+			if opts.archive == "debian" {
+				return openDebianSuiteChangelog(opts.component, pkg, opts.fetchSeries)
 			}
-		case parseStateInChange:
-			if strings.HasPrefix(line, changePrefix) {
-				processChangeLine(line)
-			} else if strings.HasPrefix(line, detailHeadPrefix) {
-				processDetailHeadLine(line)
-			} else if strings.HasPrefix(line, maintainerLinePrefix) {
-				if err := processMaintainerLine(line); err != nil {
+			return openSeriesChangelog(opts.launchpad, opts.mirror, pkg, opts.fetchSeries)
+		}}}, nil
+	case opts.gitRepo != "":
+		revs, err := gitRevisions(opts.gitRepo, opts.gitPath, opts.gitRange)
+		if err != nil {
+			return nil, err
+		}
+		var inputs []input
+		for _, rev := range revs {
+			rev := rev
+			inputs = append(inputs, input{name: rev + ":" + opts.gitPath, skipErrors: true, skipSeen: true, open: func() (io.ReadCloser, error) {
+				return openGitChangelog(opts.gitRepo, rev, opts.gitPath)
+			}})
+		}
+		return inputs, nil
+	}
+	filenames := opts.filenames
+	if len(filenames) == 0 {
+		filenames = []string{"-"}
+	}
+	var inputs []input
+	for _, filename := range filenames {
+		filename := filename
+		if opts.recursive && !isURL(filename) && filename != "-" {
+			if info, err := os.Stat(filename); err == nil && info.IsDir() {
+				found, err := findDocChangelogs(filename)
+				if err != nil {
 					return nil, err
 				}
-			}
-		case parseStateInDetail:
-			if strings.HasPrefix(line, changePrefix) {
-				processChangeLine(line)
-			} else if strings.HasPrefix(line, detailHeadPrefix) {
-				processDetailHeadLine(line)
-			} else if strings.HasPrefix(line, detailTailPrefix) {
-				processDetailTailLine(line)
-			} else if strings.HasPrefix(line, maintainerLinePrefix) {
-				if err := processMaintainerLine(line); err != nil {
-					return nil, err
+				for _, f := range found {
+					f := f
+					inputs = append(inputs, input{name: f, binaryPackage: docPackage(f), skipErrors: true, open: func() (io.ReadCloser, error) {
+						return openInput(f)
+					}})
 				}
+				continue
 			}
 		}
+		inputs = append(inputs, input{name: filename, open: func() (io.ReadCloser, error) {
+			return openInput(filename)
+		}})
 	}
-	return entries, nil
+	return inputs, nil
 }
 
-var entryLineRegex = regexp.MustCompile(`^([^ ]+) +\(([^)]+)\) +([^;]+); +(.*)`)
+// stopIfReached returns changelog.ErrStopParsing if limit is positive and
+// count has reached it.
+func stopIfReached(count, limit int) error {
+	if limit > 0 && count >= limit {
+		return changelog.ErrStopParsing
+	}
+	return nil
+}
 
-func parseEntryLine(line string) (*Entry, error) {
-	m := entryLineRegex.FindStringSubmatch(line)
-	if m == nil {
-		return nil, fmt.Errorf("invalid format entry line: %s", line)
+// openInput opens filename, stdin for "-", or fetches filename if it is
+// a URL. Input compressed with gzip, bzip2, xz or zstd is decompressed.
+func openInput(filename string) (io.ReadCloser, error) {
+	if filename == "-" {
+		return openDecompressed(io.NopCloser(os.Stdin))
 	}
-	return &Entry{
-		Package:       m[1],
-		Version:       m[2],
-		Distributions: m[3],
-		Metadata:      m[4],
-	}, nil
+	if isURL(filename) {
+		body, err := httpGetCached(filename, nil)
+		if err != nil {
+			return nil, err
+		}
+		return openDecompressed(body)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return openDecompressed(file)
 }
 
-var maintainerLineRegex = regexp.MustCompile(`^` + maintainerLinePrefix + `([^<]+) +<([^>]+)> +(.*)`)
+// writeFileAtomic writes data to a temporary file in the same directory
+// and renames it to filename, so that a partially written file is never
+// left at filename.
+func writeFileAtomic(filename string, data []byte) error {
+	f, err := createAtomicFile(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
 
-const entryDateFormat = "Mon, 02 Jan 2006 15:04:05 -0700"
+// atomicFile is a temporary file which replaces filename on Commit, so
+// that readers of filename never see a partially written file.
+type atomicFile struct {
+	*os.File
+	filename string
+}
 
-func parseMaintainerLine(e *Entry, line string) error {
-	m := maintainerLineRegex.FindStringSubmatch(line)
-	if m == nil {
-		return fmt.Errorf("invalid format maintainer line: %s", line)
-	}
-	e.MaintainerName = m[1]
-	e.EmailAddress = m[2]
-	d, err := time.Parse(entryDateFormat, m[3])
+// createAtomicFile creates a temporary file in the directory of filename
+// with the permissions of filename if it exists, or 0644 otherwise.
+func createAtomicFile(filename string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
 	if err != nil {
-		return fmt.Errorf("parse date: %s, %s", m[3], err)
+		return nil, err
 	}
-	e.Date = d
-	return nil
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(filename); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &atomicFile{File: f, filename: filename}, nil
 }
 
-func filterEntries(entries []Entry, filter *regexp.Regexp) ([]Entry, error) {
-	var matchedEntries []Entry
-	var matchedEntry *Entry
-	var matchedChange *Change
-
-	appendChange := func(entry Entry, change Change) {
-		if matchedEntry == nil {
-			matchedEntries = append(matchedEntries, Entry{
-				Package:        entry.Package,
-				Version:        entry.Version,
-				Distributions:  entry.Distributions,
-				Metadata:       entry.Metadata,
-				MaintainerName: entry.MaintainerName,
-				EmailAddress:   entry.EmailAddress,
-				Date:           entry.Date,
-			})
-			matchedEntry = &matchedEntries[len(matchedEntries)-1]
-		}
-		matchedEntry.Changes = append(matchedEntry.Changes, Change{
-			Summary: change.Summary,
-		})
-		matchedChange = &matchedEntry.Changes[len(matchedEntry.Changes)-1]
+// Commit renames the temporary file to filename.
+func (f *atomicFile) Commit() error {
+	if err := f.Sync(); err != nil {
+		f.Abort()
+		return err
 	}
-
-	appendDetail := func(entry Entry, change Change, detail Detail) {
-		if matchedChange == nil {
-			appendChange(entry, change)
-		}
-		matchedChange.Details = append(matchedChange.Details, detail)
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
 	}
+	return os.Rename(f.Name(), f.filename)
+}
 
-	for _, entry := range entries {
-		matchedEntry = nil
-		for _, change := range entry.Changes {
-			if filter.MatchString(change.Summary) {
-				appendChange(entry, change)
-			} else {
-				matchedChange = nil
-			}
-			for _, detail := range change.Details {
-				if detail.Matches(filter) {
-					appendDetail(entry, change, detail)
-				}
-			}
+// Abort removes the temporary file leaving filename as is.
+func (f *atomicFile) Abort() {
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// inSeries returns whether any of distributions separated by spaces like
+// "noble-security" is in series, ignoring the pocket suffix.
+func inSeries(distributions string, series []string) bool {
+	for _, dist := range strings.Fields(distributions) {
+		name, _, _ := strings.Cut(dist, "-")
+		if containsString(series, name) {
+			return true
 		}
 	}
-	return matchedEntries, nil
+	return false
 }
 
-func (d *Detail) Matches(re *regexp.Regexp) bool {
-	for _, line := range d.Lines {
-		if re.MatchString(line) {
+// containsString returns whether s is in list, ignoring spaces around
+// the items of list.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if strings.TrimSpace(item) == s {
 			return true
 		}
 	}
 	return false
 }
 
-func (e *Entry) String() string {
-	var b strings.Builder
-	fmt.Fprintf(&b, "%s (%s) %s; %s\n", e.Package, e.Version, e.Distributions, e.Metadata)
-	for _, change := range e.Changes {
-		fmt.Fprintf(&b, changePrefix+"%s\n", change.Summary)
-		for _, detail := range change.Details {
-			for i, line := range detail.Lines {
-				prefix := detailHeadPrefix
-				if i > 0 {
-					prefix = detailTailPrefix
-				}
-				fmt.Fprintf(&b, prefix+"%s\n", line)
-			}
+// containsInt returns whether n is in list.
+func containsInt(list []int, n int) bool {
+	for _, item := range list {
+		if item == n {
+			return true
 		}
 	}
-	fmt.Fprintf(&b, maintainerLinePrefix+"%s <%s> %s", e.MaintainerName, e.EmailAddress, e.Date.Format(entryDateFormat))
-	return b.String()
+	return false
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+const runTestChangelog = `linux (6.8.0-45.45) noble; urgency=medium
+
+  * noble/linux: 6.8.0-45.45 -proposed tracker (LP: #2078100)
+
+  * CVE-2024-26800
+    - tls: fix use-after-free on failed backlog decryption
+
+  * Noble update: upstream stable patchset 2024-07-09 (LP: #2072617)
+    - drm/amd/display: Fix division by zero
+
+ -- Stefan Bader <stefan.bader@canonical.com>  Fri, 30 Aug 2024 14:04:45 +0200
+
+linux (6.8.0-44.44) noble; urgency=medium
+
+  * noble/linux: 6.8.0-44.44 -proposed tracker (LP: #2076435)
+
+  * Packaging resync (LP: #1786013)
+    - [Packaging] update annotations scripts
+
+ -- Stefan Bader <stefan.bader@canonical.com>  Fri, 16 Aug 2024 13:39:59 +0200
+
+linux (6.8.0-43.43) noble-security; urgency=medium
+
+  * CVE-2024-1086
+    - netfilter: nf_tables: reject QUEUE/DROP verdict parameters
+
+ -- Stefan Bader <stefan.bader@canonical.com>  Mon, 05 Aug 2024 10:11:12 +0200
+
+linux (6.8.0-42.42) noble; urgency=medium
+
+  * noble/linux: 6.8.0-42.42 -proposed tracker (LP: #2073788)
+
+  * Miscellaneous Ubuntu changes
+    - [Config] update annotations
+
+ -- Stefan Bader <stefan.bader@canonical.com>  Fri, 26 Jul 2024 09:00:00 +0200
+`
+
+const runTestAWSChangelog = `linux-aws (6.8.0-1015.16) noble; urgency=medium
+
+  * noble/linux-aws: 6.8.0-1015.16 -proposed tracker (LP: #2078099)
+
+  * Miscellaneous Ubuntu changes
+    - [Config] aws: update annotations
+
+ -- John Cabaj <john.cabaj@canonical.com>  Mon, 02 Sep 2024 17:01:53 -0500
+
+linux-aws (6.8.0-1014.15) noble; urgency=medium
+
+  * noble/linux-aws: 6.8.0-1014.15 -proposed tracker (LP: #2076434)
+
+ -- John Cabaj <john.cabaj@canonical.com>  Mon, 19 Aug 2024 17:01:53 -0500
+`
+
+// runForTest runs with opts reading changelogs from files in order, and
+// returns the output of format.
+func runForTest(t *testing.T, opts options, format string, changelogs ...string) []byte {
+	t.Helper()
+	dir := t.TempDir()
+	for i, c := range changelogs {
+		filename := filepath.Join(dir, fmt.Sprintf("%d.changelog", i))
+		if err := os.WriteFile(filename, []byte(c), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		opts.filenames = append(opts.filenames, filename)
+	}
+	if opts.matchMode == "" {
+		opts.matchMode = matchModeAny
+	}
+	opts.color = "never"
+	output := filepath.Join(dir, "output")
+	opts.outputs = []outputTarget{{format: format, filename: output}}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRunCounts(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts options
+		// want are the versions of the written entries with the numbers
+		// of the changes.
+		want string
+	}{
+		{"all", options{}, "6.8.0-45.45:3 6.8.0-44.44:2 6.8.0-43.43:1 6.8.0-42.42:2 6.8.0-1015.16:2 6.8.0-1014.15:1"},
+		{"max-count for each input", options{maxCount: 4}, "6.8.0-45.45:3 6.8.0-44.44:1 6.8.0-1015.16:2 6.8.0-1014.15:1"},
+		{"max-count of changes", options{maxCount: 2}, "6.8.0-45.45:2 6.8.0-1015.16:2"},
+		{"max-count-total", options{maxCountTotal: 4}, "6.8.0-45.45:3 6.8.0-44.44:1"},
+		{"max-count-total across inputs", options{maxCountTotal: 9}, "6.8.0-45.45:3 6.8.0-44.44:2 6.8.0-43.43:1 6.8.0-42.42:2 6.8.0-1015.16:1"},
+		{"max-count and max-count-total", options{maxCount: 1, maxCountTotal: 2}, "6.8.0-45.45:1 6.8.0-1015.16:1"},
+		{"limit", options{limit: 2}, "6.8.0-45.45:3 6.8.0-44.44:2"},
+		{"limit across inputs", options{limit: 5}, "6.8.0-45.45:3 6.8.0-44.44:2 6.8.0-43.43:1 6.8.0-42.42:2 6.8.0-1015.16:2"},
+		{"skip", options{skip: 3}, "6.8.0-42.42:2 6.8.0-1015.16:2 6.8.0-1014.15:1"},
+		{"skip across inputs", options{skip: 5}, "6.8.0-1014.15:1"},
+		{"skip and limit", options{skip: 1, limit: 2}, "6.8.0-44.44:2 6.8.0-43.43:1"},
+		{"max-parse-entries", options{maxParseEntries: 2}, "6.8.0-45.45:3 6.8.0-44.44:2 6.8.0-1015.16:2 6.8.0-1014.15:1"},
+		{"max-parse-entries and limit", options{maxParseEntries: 1, limit: 3}, "6.8.0-45.45:3 6.8.0-1015.16:2"},
+
+		// The counts are of matched entries and changes.
+		{"filter", options{filters: []string{"CVE"}}, "6.8.0-45.45:1 6.8.0-43.43:1"},
+		{"filter and limit", options{filters: []string{"CVE"}, limit: 1}, "6.8.0-45.45:1"},
+		{"filter and skip", options{filters: []string{"CVE"}, skip: 1}, "6.8.0-43.43:1"},
+		{"filter and max-count", options{filters: []string{"tracker"}, maxCount: 2}, "6.8.0-45.45:1 6.8.0-44.44:1 6.8.0-1015.16:1 6.8.0-1014.15:1"},
+		{"filter and max-count-total", options{filters: []string{"tracker"}, maxCountTotal: 4}, "6.8.0-45.45:1 6.8.0-44.44:1 6.8.0-42.42:1 6.8.0-1015.16:1"},
+		// Entries parsed without a match count for -max-parse-entries.
+		{"filter and max-parse-entries", options{filters: []string{"CVE"}, maxParseEntries: 2}, "6.8.0-45.45:1"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			output := runForTest(t, test.opts, "ndjson", runTestChangelog, runTestAWSChangelog)
+			var got []string
+			s := bufio.NewScanner(strings.NewReader(string(output)))
+			for s.Scan() {
+				var entry Entry
+				if err := json.Unmarshal(s.Bytes(), &entry); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, fmt.Sprintf("%s:%d", entry.Version, len(entry.Changes)))
+			}
+			if strings.Join(got, " ") != test.want {
+				t.Errorf("run() wrote %q, want %q", strings.Join(got, " "), test.want)
+			}
+		})
+	}
+}
+
+// goldenTimeRegex matches the time of generation in the html output.
+var goldenTimeRegex = regexp.MustCompile(`Generated at [^<]*`)
+
+func TestRunGoldenOutputs(t *testing.T) {
+	for _, format := range outputFormatNames() {
+		t.Run(format, func(t *testing.T) {
+			output := runForTest(t, options{}, format, runTestChangelog)
+			output = goldenTimeRegex.ReplaceAll(output, []byte("Generated at TIME"))
+			golden := filepath.Join("testdata", "golden", format)
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, output, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%s (run the test with -update to create it)", err)
+			}
+			if string(output) != string(want) {
+				t.Errorf("%s output differs from %s:\n%s", format, golden, output)
+			}
+		})
+	}
+}
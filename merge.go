@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+func runMergeFiles(args []string) error {
+	fs := flag.NewFlagSet("merge-files", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: merge-files [-output file] BASE OURS THEIRS\n")
+		fs.PrintDefaults()
+	}
+	output := fs.String("output", "-", `output filename ("-" for stdout)`)
+	addErrorsFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 3 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var changelogs [3]*rawChangelog
+	for i, filename := range fs.Args() {
+		c, err := readRawChangelogFile(filename)
+		if err != nil {
+			return err
+		}
+		changelogs[i] = c
+	}
+
+	var b strings.Builder
+	conflicts := mergeChangelogs(&b, changelogs[0], changelogs[1], changelogs[2], fs.Arg(1), fs.Arg(2))
+	if *output == "-" {
+		if _, err := io.WriteString(os.Stdout, b.String()); err != nil {
+			return err
+		}
+	} else if err := writeFileAtomic(*output, []byte(b.String())); err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("merge-files found %d conflict(s)", conflicts)
+	}
+	return nil
+}
+
+// rawEntry is the text of an entry from the heading line to the trailer line.
+type rawEntry struct {
+	version string
+	text    string
+}
+
+type rawChangelog struct {
+	entries []rawEntry
+	// trailer is the text after the last entry, like Emacs local variables.
+	trailer string
+}
+
+func (c *rawChangelog) entryTexts() map[string]string {
+	m := make(map[string]string, len(c.entries))
+	for _, e := range c.entries {
+		m[e.version] = e.text
+	}
+	return m
+}
+
+func readRawChangelogFile(filename string) (*rawChangelog, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readRawChangelog(file)
+}
+
+// readRawChangelog splits a changelog into entries keeping their text as is.
+func readRawChangelog(r io.Reader) (*rawChangelog, error) {
+	br := bufio.NewReader(r)
+	c := &rawChangelog{}
+	var text strings.Builder
+	version := ""
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		if version == "" {
+			if entry, err := changelog.ParseEntryLine(strings.TrimRight(line, "\n")); err == nil && line[0] != ' ' {
+				version = entry.Version
+				text.Reset()
+			} else {
+				text.WriteString(line)
+				continue
+			}
+		}
+		text.WriteString(line)
+		if strings.HasPrefix(line, changelog.MaintainerLinePrefix) {
+			c.entries = append(c.entries, rawEntry{version: version, text: text.String()})
+			text.Reset()
+			version = ""
+		}
+	}
+	if version != "" {
+		c.entries = append(c.entries, rawEntry{version: version, text: text.String()})
+		text.Reset()
+	}
+	c.trailer = strings.TrimLeft(text.String(), "\n")
+	return c, nil
+}
+
+// mergeChangelogs does a three-way merge of entries in ours and theirs
+// which both derived from base, and writes merged entries in decreasing
+// version order to w. An entry changed on both sides, or changed on one
+// side and removed on the other, is a conflict and written with conflict
+// markers. It returns the number of conflicts.
+func mergeChangelogs(w io.StringWriter, base, ours, theirs *rawChangelog, oursName, theirsName string) int {
+	baseTexts := base.entryTexts()
+	oursTexts := ours.entryTexts()
+	theirsTexts := theirs.entryTexts()
+
+	var versions []string
+	seen := make(map[string]bool)
+	for _, c := range []*rawChangelog{ours, theirs} {
+		for _, e := range c.entries {
+			if !seen[e.version] {
+				seen[e.version] = true
+				versions = append(versions, e.version)
+			}
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return changelog.CompareVersions(versions[i], versions[j]) > 0
+	})
+
+	conflicts := 0
+	first := true
+	writeText := func(text string) {
+		if !first {
+			w.WriteString("\n")
+		}
+		w.WriteString(strings.TrimRight(text, "\n") + "\n")
+		first = false
+	}
+	for _, v := range versions {
+		baseText, inBase := baseTexts[v]
+		oursText, inOurs := oursTexts[v]
+		theirsText, inTheirs := theirsTexts[v]
+		switch {
+		case inOurs && inTheirs && oursText == theirsText:
+			writeText(oursText)
+		case inOurs && inTheirs && inBase && oursText == baseText:
+			writeText(theirsText)
+		case inOurs && inTheirs && inBase && theirsText == baseText:
+			writeText(oursText)
+		case inOurs && !inTheirs && (!inBase || oursText == baseText):
+			if !inBase {
+				writeText(oursText)
+			}
+		case !inOurs && inTheirs && (!inBase || theirsText == baseText):
+			if !inBase {
+				writeText(theirsText)
+			}
+		default:
+			conflicts++
+			writeText(fmt.Sprintf("<<<<<<< %s\n%s=======\n%s>>>>>>> %s\n",
+				oursName, ensureNewline(oursText), ensureNewline(theirsText), theirsName))
+		}
+	}
+	if ours.trailer != "" {
+		writeText(ours.trailer)
+	}
+	return conflicts
+}
+
+func ensureNewline(s string) string {
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		return s + "\n"
+	}
+	return s
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mergeTestEntry returns the text of an entry of version with a change.
+func mergeTestEntry(version, change string) string {
+	return "linux (" + version + ") noble; urgency=medium\n" +
+		"\n" +
+		"  * " + change + "\n" +
+		"\n" +
+		" -- John Doe <john.doe@example.com>  Tue, 10 Sep 2024 12:00:00 +0000\n"
+}
+
+// mergeTestChangelog joins entries with blank lines like a changelog.
+func mergeTestChangelog(entries ...string) string {
+	return strings.Join(entries, "\n")
+}
+
+func TestMergeChangelogs(t *testing.T) {
+	v1 := mergeTestEntry("1.0-1", "initial release")
+	v1ours := mergeTestEntry("1.0-1", "initial release (ours)")
+	v1theirs := mergeTestEntry("1.0-1", "initial release (theirs)")
+	v2 := mergeTestEntry("1.0-2", "fix build")
+	v2theirs := mergeTestEntry("1.0-2", "fix the build")
+	v3 := mergeTestEntry("1.0-3", "new upstream release")
+	v10 := mergeTestEntry("1.0-10", "security fixes")
+	for _, test := range []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		wantConflicts      int
+	}{
+		{
+			name:   "interleaved by version",
+			base:   mergeTestChangelog(v1),
+			ours:   mergeTestChangelog(v10, v2, v1),
+			theirs: mergeTestChangelog(v3, v1),
+			want:   mergeTestChangelog(v10, v3, v2, v1),
+		},
+		{
+			name:   "added on both sides",
+			base:   mergeTestChangelog(v1),
+			ours:   mergeTestChangelog(v2, v1),
+			theirs: mergeTestChangelog(v2, v1),
+			want:   mergeTestChangelog(v2, v1),
+		},
+		{
+			name:   "changed on ours",
+			base:   mergeTestChangelog(v2, v1),
+			ours:   mergeTestChangelog(v2, v1ours),
+			theirs: mergeTestChangelog(v3, v2, v1),
+			want:   mergeTestChangelog(v3, v2, v1ours),
+		},
+		{
+			name:   "changed on theirs",
+			base:   mergeTestChangelog(v2, v1),
+			ours:   mergeTestChangelog(v3, v2, v1),
+			theirs: mergeTestChangelog(v2theirs, v1),
+			want:   mergeTestChangelog(v3, v2theirs, v1),
+		},
+		{
+			name:   "deleted on ours",
+			base:   mergeTestChangelog(v2, v1),
+			ours:   mergeTestChangelog(v1),
+			theirs: mergeTestChangelog(v3, v2, v1),
+			want:   mergeTestChangelog(v3, v1),
+		},
+		{
+			name:   "deleted on theirs",
+			base:   mergeTestChangelog(v2, v1),
+			ours:   mergeTestChangelog(v3, v2, v1),
+			theirs: mergeTestChangelog(v2),
+			want:   mergeTestChangelog(v3, v2),
+		},
+		{
+			name:   "changed on both sides",
+			base:   mergeTestChangelog(v2, v1),
+			ours:   mergeTestChangelog(v2, v1ours),
+			theirs: mergeTestChangelog(v2, v1theirs),
+			want: mergeTestChangelog(v2,
+				"<<<<<<< ours\n"+v1ours+"=======\n"+v1theirs+">>>>>>> theirs\n"),
+			wantConflicts: 1,
+		},
+		{
+			name:   "added differently on both sides",
+			base:   mergeTestChangelog(v1),
+			ours:   mergeTestChangelog(v2, v1),
+			theirs: mergeTestChangelog(v2theirs, v1),
+			want: mergeTestChangelog(
+				"<<<<<<< ours\n"+v2+"=======\n"+v2theirs+">>>>>>> theirs\n", v1),
+			wantConflicts: 1,
+		},
+		{
+			name:   "deleted on ours and changed on theirs",
+			base:   mergeTestChangelog(v2, v1),
+			ours:   mergeTestChangelog(v2),
+			theirs: mergeTestChangelog(v2, v1theirs),
+			want: mergeTestChangelog(v2,
+				"<<<<<<< ours\n=======\n"+v1theirs+">>>>>>> theirs\n"),
+			wantConflicts: 1,
+		},
+		{
+			name:   "trailer of ours",
+			base:   mergeTestChangelog(v1),
+			ours:   mergeTestChangelog(v1, "Local variables:\nmode: debian-changelog\nEnd:\n"),
+			theirs: mergeTestChangelog(v2, v1),
+			want:   mergeTestChangelog(v2, v1, "Local variables:\nmode: debian-changelog\nEnd:\n"),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var changelogs [3]*rawChangelog
+			for i, s := range []string{test.base, test.ours, test.theirs} {
+				c, err := readRawChangelog(strings.NewReader(s))
+				if err != nil {
+					t.Fatal(err)
+				}
+				changelogs[i] = c
+			}
+			var b strings.Builder
+			conflicts := mergeChangelogs(&b, changelogs[0], changelogs[1], changelogs[2], "ours", "theirs")
+			if got := b.String(); got != test.want {
+				t.Errorf("mergeChangelogs() wrote\n%s\nwant\n%s", got, test.want)
+			}
+			if conflicts != test.wantConflicts {
+				t.Errorf("mergeChangelogs() = %d, want %d", conflicts, test.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestRunMergeFilesFailsOnConflicts(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, entries ...string) string {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(mergeTestChangelog(entries...)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	base := write("base", mergeTestEntry("1.0-1", "initial release"))
+	ours := write("ours", mergeTestEntry("1.0-1", "initial release (ours)"))
+	theirs := write("theirs", mergeTestEntry("1.0-1", "initial release (theirs)"))
+	output := filepath.Join(dir, "merged")
+
+	err := runMergeFiles([]string{"-output", output, base, ours, theirs})
+	if err == nil || err.Error() != "merge-files found 1 conflict(s)" {
+		t.Errorf("runMergeFiles() = %v, want the error of a conflict", err)
+	}
+	merged, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(merged), "<<<<<<< "+ours+"\n") {
+		t.Errorf("runMergeFiles() wrote %q, want conflict markers", merged)
+	}
+
+	if err := runMergeFiles([]string{"-output", output, base, ours, ours}); err != nil {
+		t.Errorf("runMergeFiles() without conflicts = %v", err)
+	}
+}
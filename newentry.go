@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+func runNewEntry(args []string) error {
+	fs := flag.NewFlagSet("new-entry", flag.ExitOnError)
+	filename := fs.String("file", "debian/changelog", "changelog filename to prepend the new entry to")
+	pkg := fs.String("package", "", "source package name (default: the one of the previous entry)")
+	version := fs.String("version", "", "version (default: the previous version with the last number incremented)")
+	distribution := fs.String("distribution", "", "distribution (default: the one of the previous entry)")
+	urgency := fs.String("urgency", "medium", "urgency")
+	maintainer := fs.String("maintainer", "", `maintainer in the form of "Full Name <email>" (default: from $DEBFULLNAME and $DEBEMAIL,
+or the maintainer in the config file)`)
+	configFilename := fs.String("config", "", "config filename (default: $XDG_CONFIG_HOME/"+appName+"/config.json)")
+	var changes stringsFlag
+	fs.Var(&changes, "change", "change summary (can be specified multiple times)")
+	addErrorsFlag(fs)
+	fs.Parse(args)
+
+	if len(changes) == 0 {
+		return errors.New("at least one -change must be specified")
+	}
+
+	content, err := os.ReadFile(*filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	var prev *Entry
+	err = changelog.ParseFunc(bytes.NewReader(content), func(entry Entry) error {
+		prev = &entry
+		return changelog.ErrStopParsing
+	}, nil)
+	if err != nil && err != changelog.ErrStopParsing {
+		return err
+	}
+
+	if prev != nil {
+		if *pkg == "" {
+			*pkg = prev.Package
+		}
+		if *version == "" {
+			*version = incrementVersion(prev.Version)
+		}
+		if *distribution == "" {
+			*distribution = prev.Distributions
+		}
+	}
+	if *pkg == "" || *version == "" || *distribution == "" {
+		return errors.New("-package, -version and -distribution must be specified when there is no previous entry")
+	}
+	if err := changelog.ValidateVersion(*version); err != nil {
+		return err
+	}
+	if prev != nil && changelog.CompareVersions(*version, prev.Version) <= 0 {
+		return fmt.Errorf("version %s must be newer than previous version %s", *version, prev.Version)
+	}
+	if *maintainer == "" {
+		cfg, err := loadConfig(*configFilename)
+		if err != nil {
+			return err
+		}
+		*maintainer = maintainerFromEnv(cfg.Maintainer)
+	}
+	var m Entry
+	if err := changelog.ParseMaintainerLine(&m, changelog.MaintainerLinePrefix+*maintainer+"  "+time.Now().Format(changelog.DateFormat)); err != nil {
+		return fmt.Errorf("invalid maintainer %q, set -maintainer, $DEBFULLNAME and $DEBEMAIL, or the maintainer in the config file", *maintainer)
+	}
+	builder := changelog.NewEntry(*pkg, *version, *distribution).
+		Urgency(*urgency).
+		Maintainer(m.MaintainerName, m.EmailAddress)
+	for _, change := range changes {
+		builder.AddChange(change)
+	}
+	entry, err := builder.Finalize()
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := changelog.NewWriter(&b).WriteEntry(entry); err != nil {
+		return err
+	}
+	if len(content) > 0 {
+		b.WriteString("\n")
+		b.Write(content)
+	}
+	return writeFileAtomic(*filename, b.Bytes())
+}
+
+// maintainerFromEnv returns the maintainer from environment variables
+// in the same way as dch does, falling back to the name and the email
+// in cfg for those not set.
+func maintainerFromEnv(cfg maintainerConfig) string {
+	name := os.Getenv("DEBFULLNAME")
+	if name == "" {
+		name = os.Getenv("NAME")
+	}
+	email := os.Getenv("DEBEMAIL")
+	if email == "" {
+		email = os.Getenv("EMAIL")
+	}
+	if email == "" {
+		email = cfg.Email
+	}
+	if name == "" && !strings.Contains(email, "<") {
+		name = cfg.Name
+	}
+	if strings.Contains(email, "<") {
+		// DEBEMAIL may be in the form of "Full Name <email>".
+		if name == "" {
+			return email
+		}
+		_, email, _ = strings.Cut(email, "<")
+		email = strings.TrimSuffix(email, ">")
+	}
+	return fmt.Sprintf("%s <%s>", name, email)
+}
+
+// incrementVersion returns version with the last number incremented,
+// for example "6.8.0-45.46" for "6.8.0-45.45".
+func incrementVersion(version string) string {
+	end := len(version)
+	for end > 0 && (version[end-1] < '0' || version[end-1] > '9') {
+		end--
+	}
+	start := end
+	for start > 0 && '0' <= version[start-1] && version[start-1] <= '9' {
+		start--
+	}
+	if start == end {
+		return version + "1"
+	}
+	n, err := strconv.Atoi(version[start:end])
+	if err != nil {
+		return version + "1"
+	}
+	return version[:start] + strconv.Itoa(n+1) + version[end:]
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestMaintainerFromEnvFallsBackToConfig(t *testing.T) {
+	cfg := maintainerConfig{Name: "Conf Name", Email: "conf@example.com"}
+	for _, test := range []struct {
+		env  map[string]string
+		want string
+	}{
+		{nil, "Conf Name <conf@example.com>"},
+		{map[string]string{"DEBFULLNAME": "Env Name"}, "Env Name <conf@example.com>"},
+		{map[string]string{"EMAIL": "env@example.com"}, "Conf Name <env@example.com>"},
+		{map[string]string{"DEBEMAIL": "Env Name <env@example.com>"}, "Env Name <env@example.com>"},
+	} {
+		for _, key := range []string{"DEBFULLNAME", "NAME", "DEBEMAIL", "EMAIL"} {
+			t.Setenv(key, test.env[key])
+		}
+		if got := maintainerFromEnv(cfg); got != test.want {
+			t.Errorf("maintainerFromEnv() with %v = %q, want %q", test.env, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+// entryWriter writes matched entries in an output format.
+type entryWriter interface {
+	// WriteEntry is called for each matched entry.
+	WriteEntry(entry Entry) error
+	// Close is called after all entries are written, so that formats
+	// which aggregate entries can write their output.
+	Close() error
+}
+
+type outputOptions struct {
+	showAge     bool
+	withVersion bool
+	// color is the -color mode, "auto", "always" or "never".
+	color string
+	// print0 terminates entries with NUL instead of newlines in the text
+	// and oneline formats.
+	print0 bool
+	// highlights are regular expressions matching substrings to be
+	// colored in the colored text output.
+	highlights []*regexp.Regexp
+	// maxMemory is the size of entries kept in memory by formats which
+	// write output after all entries, over which they are spooled to a
+	// temporary file. 0 means no limit.
+	maxMemory int64
+}
+
+// entryWriterFactories maps output format names to functions to
+// create an entryWriter for the formats which need options or write
+// output after all entries. The other formats, like "ndjson" and
+// "markdown", are the formatters registered in the changelog package,
+// which are looked up with entryWriterFactory. "text" is the registered
+// formatter with the options of the text output.
+var entryWriterFactories = map[string]func(w io.Writer, opts outputOptions) entryWriter{
+	"text":       newTextWriter,
+	"changelog":  newChangelogWriter,
+	"raw":        newRawWriter,
+	"json":       newJSONWriter,
+	"oneline":    newOnelineWriter,
+	"yaml":       newYAMLWriter,
+	"cve-groups": newCVEGroupWriter,
+	"csv":        newCSVWriter,
+	"parquet":    newParquetWriter,
+	"proto":      newProtoWriter,
+	"sql":        newSQLWriter,
+	"tsv":        newTSVWriter,
+	"atom":       newAtomWriter,
+	"html":       newHTMLWriter,
+	"xlsx":       newXLSXWriter,
+	"xml":        newXMLWriter,
+	"lp-bugs":    newLPBugsWriter,
+}
+
+// entryWriterFactory returns the function to create an entryWriter for
+// format, which is one of entryWriterFactories, or a formatter registered
+// with changelog.RegisterFormatter otherwise.
+func entryWriterFactory(format string) (func(w io.Writer, opts outputOptions) entryWriter, bool) {
+	if newWriter, ok := entryWriterFactories[format]; ok {
+		return newWriter, true
+	}
+	f, ok := changelog.LookupFormatter(format)
+	if !ok {
+		return nil, false
+	}
+	return func(w io.Writer, opts outputOptions) entryWriter {
+		return &formatterWriter{w: w, f: f}
+	}, true
+}
+
+// formatterWriter writes entries with a changelog.Formatter.
+type formatterWriter struct {
+	w io.Writer
+	f changelog.Formatter
+}
+
+func (f *formatterWriter) WriteEntry(entry Entry) error {
+	return f.f.WriteEntry(f.w, entry)
+}
+
+func (f *formatterWriter) Close() error {
+	return nil
+}
+
+func outputFormatNames() []string {
+	names := changelog.FormatterNames()
+	for name := range entryWriterFactories {
+		if _, ok := changelog.LookupFormatter(name); !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// outputTarget is an output format and a filename to write output to.
+// If command is set, output is written to stdin of the command instead.
+type outputTarget struct {
+	format   string
+	filename string
+	command  []string
+}
+
+// parseOutputTarget parses a target in the form of "format=filename".
+// The filename "-" means stdout.
+func parseOutputTarget(s string) (outputTarget, error) {
+	format, filename, ok := strings.Cut(s, "=")
+	if !ok || format == "" || filename == "" {
+		return outputTarget{}, fmt.Errorf("invalid output %q, must be in the form of format=filename", s)
+	}
+	if _, ok := entryWriterFactory(format); !ok {
+		return outputTarget{}, fmt.Errorf("unknown output format %q, must be one of %s",
+			format, strings.Join(outputFormatNames(), ", "))
+	}
+	return outputTarget{format: format, filename: filename}, nil
+}
+
+func hasOutputFormat(targets []outputTarget, format string) bool {
+	for _, t := range targets {
+		if t.format == format {
+			return true
+		}
+	}
+	return false
+}
+
+// outputs writes matched entries to all targets.
+type outputs struct {
+	writers []entryWriter
+	// closers are stdin of output commands.
+	closers []io.Closer
+	files   []*atomicFile
+}
+
+func openOutputs(targets []outputTarget, opts outputOptions) (*outputs, error) {
+	o := &outputs{}
+	for _, t := range targets {
+		var w io.Writer = os.Stdout
+		if t.command != nil {
+			cmd, err := startOutputCommand(t.command)
+			if err != nil {
+				o.Abort()
+				return nil, err
+			}
+			o.closers = append(o.closers, cmd)
+			w = cmd
+		} else if t.filename != "-" {
+			file, err := createAtomicFile(t.filename)
+			if err != nil {
+				o.Abort()
+				return nil, err
+			}
+			o.files = append(o.files, file)
+			w = file
+		}
+		newWriter, _ := entryWriterFactory(t.format)
+		o.writers = append(o.writers, newWriter(w, opts))
+	}
+	return o, nil
+}
+
+func (o *outputs) WriteEntry(entry Entry) error {
+	for _, w := range o.writers {
+		if err := w.WriteEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all writers and commits output files if all output is
+// written successfully. It is safe to call Close or Abort after the
+// first call.
+func (o *outputs) Close() error {
+	var firstErr error
+	for _, w := range o.writers {
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	o.writers = nil
+	if err := o.closeCommands(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	for _, file := range o.files {
+		if firstErr != nil {
+			file.Abort()
+		} else if err := file.Commit(); err != nil {
+			firstErr = err
+		}
+	}
+	o.files = nil
+	return firstErr
+}
+
+// Abort discards output files without closing writers, so that files
+// are left as they were on errors.
+func (o *outputs) Abort() {
+	o.writers = nil
+	o.closeCommands()
+	for _, file := range o.files {
+		file.Abort()
+	}
+	o.files = nil
+}
+
+func (o *outputs) closeCommands() error {
+	var firstErr error
+	for _, c := range o.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	o.closers = nil
+	return firstErr
+}
+
+// textWriter writes entries with the "text" formatter registered in the
+// changelog package, or in the same format with -color, -age or -print0.
+// The input is shown before its entries like head(1).
+type textWriter struct {
+	w          io.Writer
+	f          changelog.Formatter
+	showAge    bool
+	color      bool
+	highlights []*regexp.Regexp
+	print0     bool
+	// filename is the Filename of the last entry.
+	filename string
+}
+
+func newTextWriter(w io.Writer, opts outputOptions) entryWriter {
+	f, _ := changelog.LookupFormatter("text")
+	return &textWriter{w: w, f: f, showAge: opts.showAge, color: useColor(opts.color, w), highlights: opts.highlights, print0: opts.print0}
+}
+
+func (t *textWriter) WriteEntry(entry Entry) error {
+	if entry.Filename != t.filename {
+		if _, err := io.WriteString(t.w, "==> "+inputName(entry)+" <==\n"); err != nil {
+			return err
+		}
+		t.filename = entry.Filename
+	}
+	age := ""
+	if t.showAge && !entry.Date.IsZero() {
+		age = " (" + humanizeAge(time.Since(entry.Date)) + ")"
+	}
+	if !t.color && age == "" && !t.print0 {
+		return t.f.WriteEntry(t.w, entry)
+	}
+	text := entry.String() + age
+	if t.color {
+		text = coloredEntryString(&entry, age, t.highlights)
+	}
+	terminator := "\n\n"
+	if t.print0 {
+		terminator = "\x00"
+	}
+	_, err := io.WriteString(t.w, text+terminator)
+	return err
+}
+
+func (t *textWriter) Close() error {
+	return nil
+}
+
+// onelineWriter writes a line for each entry with the version, the date,
+// the number of matched changes and the first matched summary, like
+// "git log --oneline".
+type onelineWriter struct {
+	w      io.Writer
+	print0 bool
+}
+
+func newOnelineWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &onelineWriter{w: w, print0: opts.print0}
+}
+
+func (o *onelineWriter) WriteEntry(entry Entry) error {
+	terminator := "\n"
+	if o.print0 {
+		terminator = "\x00"
+	}
+	prefix := ""
+	if entry.Filename != "" {
+		prefix = inputName(entry) + ": "
+	}
+	_, err := io.WriteString(o.w, prefix+onelineSummary(entry)+terminator)
+	return err
+}
+
+func (o *onelineWriter) Close() error {
+	return nil
+}
+
+// inputName returns Filename with BinaryPackage if any.
+func inputName(e Entry) string {
+	if e.BinaryPackage != "" {
+		return e.Filename + " (" + e.BinaryPackage + ")"
+	}
+	return e.Filename
+}
+
+func onelineSummary(entry Entry) string {
+	summary := ""
+	if len(entry.Changes) > 0 {
+		summary = entry.Changes[0].Summary
+		if len(entry.Changes) > 1 {
+			summary += "…"
+		}
+	}
+	changes := "changes"
+	if len(entry.Changes) == 1 {
+		changes = "change"
+	}
+	return fmt.Sprintf("%s  %s  %d matched %s  %s", entry.Version, onelineDate(entry), len(entry.Changes), changes, summary)
+}
+
+// onelineDate returns the date of entry without the time, or the date as
+// written if it cannot be parsed.
+func onelineDate(entry Entry) string {
+	if entry.Date.IsZero() && entry.RawDate != "" {
+		return entry.RawDate
+	}
+	return entry.Date.Format("2006-01-02")
+}
+
+// rawWriter writes the original text of entries with matched changes as
+// is, including the blank lines between them, comments before the first
+// entry and the text after the last entry, so that the input is
+// reproduced if all entries are matched. Entries which do not have the
+// original text, like ones decoded from JSON, are written in the
+// changelog format.
+type rawWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newRawWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &rawWriter{w: w}
+}
+
+func (r *rawWriter) WriteEntry(entry Entry) error {
+	if entry.Raw() == "" {
+		if r.count > 0 {
+			if _, err := io.WriteString(r.w, "\n"); err != nil {
+				return err
+			}
+		}
+		r.count++
+		_, err := entry.WriteTo(r.w)
+		return err
+	}
+	text := entry.Raw() + entry.RawTrailer()
+	if r.count > 0 || strings.TrimSpace(entry.RawSeparator()) != "" {
+		text = entry.RawSeparator() + text
+	}
+	r.count++
+	_, err := io.WriteString(r.w, text)
+	return err
+}
+
+func (r *rawWriter) Close() error {
+	return nil
+}
+
+// jsonWriter writes entries as a JSON array. Entries are written as they
+// are matched so that the whole result is not kept in memory.
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &jsonWriter{w: w}
+}
+
+func (j *jsonWriter) WriteEntry(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if j.count == 0 {
+		sep = "[\n"
+	}
+	j.count++
+	_, err = fmt.Fprintf(j.w, "%s%s", sep, data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	if j.count == 0 {
+		_, err := io.WriteString(j.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(j.w, "\n]\n")
+	return err
+}
+
+// isTerminal returns whether file is a terminal.
+func isTerminal(file *os.File) bool {
+	fi, err := file.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// humanizeAge returns d in a rough human readable form like "2 weeks ago".
+func humanizeAge(d time.Duration) string {
+	if d < 0 {
+		return "in the future"
+	}
+	const (
+		day   = 24 * time.Hour
+		week  = 7 * day
+		month = 30 * day
+		year  = 365 * day
+	)
+	units := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"year", year},
+		{"month", month},
+		{"week", week},
+		{"day", day},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, u := range units {
+		if n := int(d / u.d); n > 0 {
+			if n == 1 {
+				return fmt.Sprintf("1 %s ago", u.name)
+			}
+			return fmt.Sprintf("%d %ss ago", n, u.name)
+		}
+	}
+	return "just now"
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+func TestTextWriterMatchesFormatter(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(fmtTestChangelog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := changelog.LookupFormatter("text")
+	if !ok {
+		t.Fatal("no text formatter")
+	}
+	var got, want bytes.Buffer
+	w := newTextWriter(&got, outputOptions{color: "never"})
+	for _, entry := range entries {
+		if err := w.WriteEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.WriteEntry(&want, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("text output =\n%s\nwant:\n%s", got.String(), want.String())
+	}
+}
+
+func TestMarkdownTemplateFuncMatchesFormatter(t *testing.T) {
+	s := "# [x] *y* _z_ <w> `v`"
+	escape := templateFuncs["markdown"].(func(string) string)
+	if got, want := escape(s), changelog.EscapeMarkdown(s); got != want || !strings.Contains(got, `\#`) {
+		t.Errorf("markdown(%q) = %q, want %q", s, got, want)
+	}
+}
+
+func TestHTMLAnchorsAreUnique(t *testing.T) {
+	var b bytes.Buffer
+	w := newHTMLWriter(&b, outputOptions{})
+	for _, entry := range []Entry{
+		{Package: "linux", Version: "6.8.0-45.45", Changes: []Change{{Summary: "CVE-2024-1111"}}},
+		{Package: "linux-aws", Version: "6.8.0-45.45", Changes: []Change{{Summary: "CVE-2024-1111"}}},
+		{Package: "linux", Version: "6.8.0-45.45"},
+	} {
+		if err := w.WriteEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<h2 id="linux-6.8.0-45.45"><a href="#linux-6.8.0-45.45">`,
+		`<h2 id="linux-aws-6.8.0-45.45"><a href="#linux-aws-6.8.0-45.45">`,
+		`<h2 id="linux-6.8.0-45.45-2"><a href="#linux-6.8.0-45.45-2">`,
+		`<a id="CVE-2024-1111" `,
+		`<a id="CVE-2024-1111-2" `,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("html output does not contain %q:\n%s", want, b.String())
+		}
+	}
+}
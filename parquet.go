@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// parquetWriter writes flattened entries as a Parquet file with a column
+// for each of flatHeader. The date column is a timestamp in milliseconds,
+// which is null for dates which cannot be parsed, and the others are UTF-8
+// strings. All rows are written in one row group
+// with one uncompressed page per column, which is enough for changelogs
+// and keeps the writer free of dependencies. The values of each column
+// are kept in a spool until Close.
+// https://parquet.apache.org/docs/file-format/
+type parquetWriter struct {
+	w       io.Writer
+	columns []*spool
+	rows    int
+	// dated has runs of rows with and without a date, which are written
+	// as the definition levels of the date column.
+	dated []parquetRun
+	value bytes.Buffer
+}
+
+// parquetRun is a run of count rows which have a value or not.
+type parquetRun struct {
+	defined bool
+	count   int
+}
+
+// parquetDateColumn is the index of the date column in flatHeader.
+const parquetDateColumn = 3
+
+// Parquet enum values from parquet.thrift.
+const (
+	parquetTypeInt64          = 2
+	parquetTypeByteArray      = 6
+	parquetRequired           = 0
+	parquetOptional           = 1
+	parquetConvertedUTF8      = 0
+	parquetConvertedTimestamp = 9 // TIMESTAMP_MILLIS
+	parquetEncodingPlain      = 0
+	parquetEncodingRLE        = 3
+	parquetCodecUncompressed  = 0
+	parquetPageData           = 0
+)
+
+func newParquetWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &parquetWriter{w: w, columns: newSpools(opts.maxMemory, len(flatHeader))}
+}
+
+func (p *parquetWriter) WriteEntry(entry Entry) error {
+	for _, row := range flattenEntry(entry) {
+		for i, value := range row {
+			p.value.Reset()
+			if i == parquetDateColumn {
+				dated := !entry.Date.IsZero()
+				if n := len(p.dated); n > 0 && p.dated[n-1].defined == dated {
+					p.dated[n-1].count++
+				} else {
+					p.dated = append(p.dated, parquetRun{defined: dated, count: 1})
+				}
+				if !dated {
+					continue
+				}
+				binary.Write(&p.value, binary.LittleEndian, entry.Date.UnixMilli())
+			} else {
+				binary.Write(&p.value, binary.LittleEndian, uint32(len(value)))
+				p.value.WriteString(value)
+			}
+			if err := p.columns[i].add(p.value.Bytes()); err != nil {
+				return err
+			}
+		}
+		p.rows++
+	}
+	return nil
+}
+
+func (p *parquetWriter) Close() error {
+	for _, column := range p.columns {
+		defer column.close()
+	}
+	out := &countingWriter{w: p.w}
+	if _, err := io.WriteString(out, "PAR1"); err != nil {
+		return err
+	}
+
+	var chunks [][]byte
+	var totalSize int64
+	if p.rows > 0 {
+		for i, name := range flatHeader {
+			offset := out.n
+			var levels []byte
+			if i == parquetDateColumn {
+				levels = parquetDefinitionLevels(p.dated)
+			}
+			pageSize := int32(int64(len(levels)) + p.columns[i].size())
+			var header thriftCompactWriter
+			header.i32(1, parquetPageData)
+			header.i32(2, pageSize)
+			header.i32(3, pageSize)
+			header.beginStruct(5)
+			header.i32(1, int32(p.rows))
+			header.i32(2, parquetEncodingPlain)
+			header.i32(3, parquetEncodingRLE)
+			header.i32(4, parquetEncodingRLE)
+			header.end()
+			header.stop()
+			if _, err := out.Write(append(header.buf.Bytes(), levels...)); err != nil {
+				return err
+			}
+			if _, err := p.columns[i].WriteTo(out); err != nil {
+				return err
+			}
+			size := out.n - offset
+			totalSize += size
+
+			var chunk thriftCompactWriter
+			chunk.i64(2, offset)
+			chunk.beginStruct(3)
+			chunk.i32(1, parquetColumnType(i))
+			if i == parquetDateColumn {
+				// Elements of lists of i32 are zigzag encoded.
+				chunk.listHeader(2, thriftI32, 2)
+				chunk.varint(parquetEncodingPlain << 1)
+				chunk.varint(parquetEncodingRLE << 1)
+			} else {
+				chunk.listHeader(2, thriftI32, 1)
+				chunk.varint(parquetEncodingPlain)
+			}
+			chunk.listHeader(3, thriftBinary, 1)
+			chunk.binary(name)
+			chunk.i32(4, parquetCodecUncompressed)
+			chunk.i64(5, int64(p.rows))
+			chunk.i64(6, size)
+			chunk.i64(7, size)
+			chunk.i64(9, offset)
+			chunk.end()
+			chunk.stop()
+			chunks = append(chunks, chunk.buf.Bytes())
+		}
+	}
+
+	var meta thriftCompactWriter
+	meta.i32(1, 1)
+	meta.listHeader(2, thriftStruct, len(flatHeader)+1)
+	meta.beginElement()
+	meta.string(4, "schema")
+	meta.i32(5, int32(len(flatHeader)))
+	meta.end()
+	for i, name := range flatHeader {
+		meta.beginElement()
+		meta.i32(1, parquetColumnType(i))
+		if i == parquetDateColumn {
+			meta.i32(3, parquetOptional)
+		} else {
+			meta.i32(3, parquetRequired)
+		}
+		meta.string(4, name)
+		if i == parquetDateColumn {
+			meta.i32(6, parquetConvertedTimestamp)
+		} else {
+			meta.i32(6, parquetConvertedUTF8)
+		}
+		meta.end()
+	}
+	meta.i64(3, int64(p.rows))
+	if p.rows > 0 {
+		meta.listHeader(4, thriftStruct, 1)
+		meta.beginElement()
+		meta.listHeader(1, thriftStruct, len(chunks))
+		for _, chunk := range chunks {
+			meta.buf.Write(chunk)
+		}
+		meta.i64(2, totalSize)
+		meta.i64(3, int64(p.rows))
+		meta.end()
+	} else {
+		meta.listHeader(4, thriftStruct, 0)
+	}
+	meta.string(6, appName)
+	meta.stop()
+
+	meta.buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(meta.buf.Len())))
+	meta.buf.WriteString("PAR1")
+	_, err := out.Write(meta.buf.Bytes())
+	return err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// parquetDefinitionLevels returns the definition levels of an optional
+// column in the RLE encoding prefixed with its length, which are 1 for
+// rows with values and 0 for nulls.
+func parquetDefinitionLevels(defined []parquetRun) []byte {
+	var runs []byte
+	for _, run := range defined {
+		runs = binary.AppendUvarint(runs, uint64(run.count)<<1)
+		if run.defined {
+			runs = append(runs, 1)
+		} else {
+			runs = append(runs, 0)
+		}
+	}
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(runs))), runs...)
+}
+
+func parquetColumnType(i int) int32 {
+	if i == parquetDateColumn {
+		return parquetTypeInt64
+	}
+	return parquetTypeByteArray
+}
+
+// Thrift compact protocol types.
+// https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftCompactWriter encodes structs in the Thrift compact protocol,
+// which Parquet uses for page headers and file metadata.
+type thriftCompactWriter struct {
+	buf       bytes.Buffer
+	lastField int
+	stack     []int
+}
+
+func (t *thriftCompactWriter) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (t *thriftCompactWriter) fieldHeader(id, typ int) {
+	if delta := id - t.lastField; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta<<4 | typ))
+	} else {
+		t.buf.WriteByte(byte(typ))
+		t.varint(uint64(id<<1 ^ id>>31))
+	}
+	t.lastField = id
+}
+
+func (t *thriftCompactWriter) i32(id int, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(uint64(uint32(v<<1 ^ v>>31)))
+}
+
+func (t *thriftCompactWriter) i64(id int, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(uint64(v<<1 ^ v>>63))
+}
+
+func (t *thriftCompactWriter) string(id int, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.binary(s)
+}
+
+func (t *thriftCompactWriter) binary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftCompactWriter) listHeader(id, elemType, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size<<4 | elemType))
+	} else {
+		t.buf.WriteByte(byte(0xf0 | elemType))
+		t.varint(uint64(size))
+	}
+}
+
+// beginStruct starts a struct field, and beginElement starts a struct
+// element of a list.
+func (t *thriftCompactWriter) beginStruct(id int) {
+	t.fieldHeader(id, thriftStruct)
+	t.beginElement()
+}
+
+func (t *thriftCompactWriter) beginElement() {
+	t.stack = append(t.stack, t.lastField)
+	t.lastField = 0
+}
+
+// end ends a struct started with beginStruct or beginElement.
+func (t *thriftCompactWriter) end() {
+	t.stop()
+	t.lastField = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftCompactWriter) stop() {
+	t.buf.WriteByte(0)
+}
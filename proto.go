@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// protoWriter writes each entry as an Entry message of proto/changelog.proto
+// preceded by its size as a varint. Messages are encoded by hand since
+// they only have strings, nested messages and a timestamp.
+// https://protobuf.dev/programming-guides/encoding/
+type protoWriter struct {
+	w io.Writer
+}
+
+func newProtoWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &protoWriter{w: w}
+}
+
+func (p *protoWriter) WriteEntry(entry Entry) error {
+	msg := marshalEntryProto(entry)
+	_, err := p.w.Write(append(binary.AppendUvarint(nil, uint64(len(msg))), msg...))
+	return err
+}
+
+func (p *protoWriter) Close() error {
+	return nil
+}
+
+// Protocol buffers wire types.
+const (
+	protoVarint = 0
+	protoBytes  = 2
+)
+
+func marshalEntryProto(entry Entry) []byte {
+	var b []byte
+	b = appendProtoString(b, 1, entry.Package)
+	b = appendProtoString(b, 2, entry.Version)
+	b = appendProtoString(b, 3, entry.Distributions)
+	b = appendProtoString(b, 4, entry.Metadata)
+	b = appendProtoString(b, 5, entry.MaintainerName)
+	b = appendProtoString(b, 6, entry.EmailAddress)
+
+	// google.protobuf.Timestamp, which is not set for a date which cannot
+	// be parsed and is kept in raw_date.
+	if !entry.Date.IsZero() {
+		var ts []byte
+		if seconds := entry.Date.Unix(); seconds != 0 {
+			ts = appendProtoTag(ts, 1, protoVarint)
+			ts = binary.AppendUvarint(ts, uint64(seconds))
+		}
+		if nanos := entry.Date.Nanosecond(); nanos != 0 {
+			ts = appendProtoTag(ts, 2, protoVarint)
+			ts = binary.AppendUvarint(ts, uint64(nanos))
+		}
+		b = appendProtoBytes(b, 7, ts)
+	}
+
+	for _, change := range entry.Changes {
+		var c []byte
+		c = appendProtoString(c, 1, change.Summary)
+		for _, detail := range change.Details {
+			var d []byte
+			for _, line := range detail.Lines {
+				d = appendProtoBytes(d, 1, []byte(line))
+			}
+			c = appendProtoBytes(c, 2, d)
+		}
+		for _, line := range change.ExtraLines {
+			c = appendProtoBytes(c, 3, []byte(line))
+		}
+		for _, line := range change.LeadingLines {
+			c = appendProtoBytes(c, 4, []byte(line))
+		}
+		b = appendProtoBytes(b, 8, c)
+	}
+	b = appendProtoString(b, 9, entry.Filename)
+	b = appendProtoString(b, 10, entry.BinaryPackage)
+	for _, line := range entry.ExtraLines {
+		b = appendProtoBytes(b, 11, []byte(line))
+	}
+	b = appendProtoString(b, 12, entry.RawDate)
+	// A map is encoded as repeated entries of a key and a value.
+	keys := make([]string, 0, len(entry.MetadataFields))
+	for key := range entry.MetadataFields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var kv []byte
+		kv = appendProtoString(kv, 1, key)
+		kv = appendProtoString(kv, 2, entry.MetadataFields[key])
+		b = appendProtoBytes(b, 13, kv)
+	}
+	b = appendProtoString(b, 14, entry.Urgency)
+	return b
+}
+
+func appendProtoTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+// appendProtoString appends a string field unless it is empty, which is
+// the default value in proto3.
+func appendProtoString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendProtoBytes(b, field, []byte(s))
+}
+
+func appendProtoBytes(b []byte, field int, data []byte) []byte {
+	b = appendProtoTag(b, field, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+// unmarshalProtoFields decodes the top-level fields of a message, keeping
+// the last value of each field, which is enough for the scalar fields of
+// Entry.
+func unmarshalProtoFields(t *testing.T, b []byte) map[int][]byte {
+	t.Helper()
+	fields := make(map[int][]byte)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad tag in %x", b)
+		}
+		b = b[n:]
+		field, wireType := int(tag>>3), int(tag&7)
+		switch wireType {
+		case protoVarint:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatalf("bad varint in %x", b)
+			}
+			fields[field] = binary.AppendUvarint(nil, v)
+			b = b[n:]
+		case protoBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				t.Fatalf("bad length in %x", b)
+			}
+			fields[field] = b[n : n+int(size)]
+			b = b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", wireType)
+		}
+	}
+	return fields
+}
+
+func TestMarshalEntryProtoDate(t *testing.T) {
+	text := `linux (6.8.0-45.45) noble; urgency=medium
+
+  * good date
+
+ -- Stefan Bader <stefan.bader@canonical.com>  Fri, 30 Aug 2024 14:04:45 +0200
+
+linux (6.8.0-44.44) noble; urgency=medium
+
+  * bad date
+
+ -- John Doe <john@example.com>  someday in 1999
+`
+	entries, err := changelog.Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good := unmarshalProtoFields(t, marshalEntryProto(entries[0]))
+	ts, ok := good[7]
+	if !ok {
+		t.Fatal("date is not set for a good date")
+	}
+	seconds, _ := binary.Uvarint(unmarshalProtoFields(t, ts)[1])
+	if got, want := time.Unix(int64(seconds), 0), entries[0].Date; !got.Equal(want) {
+		t.Errorf("date = %v, want %v", got, want)
+	}
+	if _, ok := good[12]; ok {
+		t.Error("raw_date is set for a good date")
+	}
+
+	bad := unmarshalProtoFields(t, marshalEntryProto(entries[1]))
+	if ts, ok := bad[7]; ok {
+		t.Errorf("date is set to %x for a bad date", ts)
+	}
+	if got, want := string(bad[12]), "someday in 1999"; got != want {
+		t.Errorf("raw_date = %q, want %q", got, want)
+	}
+}
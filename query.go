@@ -0,0 +1,434 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+// queryNode is a node of the predicate tree of -query, which is evaluated
+// for each change with the entry of it.
+type queryNode interface {
+	eval(entry *Entry, change *Change) bool
+}
+
+type queryAnd struct{ left, right queryNode }
+
+func (q queryAnd) eval(entry *Entry, change *Change) bool {
+	return q.left.eval(entry, change) && q.right.eval(entry, change)
+}
+
+type queryOr struct{ left, right queryNode }
+
+func (q queryOr) eval(entry *Entry, change *Change) bool {
+	return q.left.eval(entry, change) || q.right.eval(entry, change)
+}
+
+type queryNot struct{ node queryNode }
+
+func (q queryNot) eval(entry *Entry, change *Change) bool {
+	return !q.node.eval(entry, change)
+}
+
+// queryPredicate is a leaf of the tree, like `version >= 6.8.0-40.40`.
+type queryPredicate func(entry *Entry, change *Change) bool
+
+func (q queryPredicate) eval(entry *Entry, change *Change) bool {
+	return q(entry, change)
+}
+
+// query is a compiled -query expression.
+type query struct {
+	root queryNode
+	// textPatterns are the regular expressions matched against the
+	// change text outside of negations, which also select details to
+	// show and are highlighted.
+	textPatterns []*regexp.Regexp
+}
+
+// queryToken is a token of -query. kind is one of "(", ")", "op" for
+// comparison operators, "string" for quoted strings and "word".
+type queryToken struct {
+	kind string
+	text string
+	pos  int
+}
+
+var queryOperators = []string{"!~", "!=", "<=", ">=", "~", "=", "<", ">"}
+
+// tokenizeQuery splits s into tokens. Words end at spaces, parentheses,
+// quotes and operator characters.
+func tokenizeQuery(s string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{kind: string(c), text: string(c), pos: i})
+			i++
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j == len(s) {
+				return nil, fmt.Errorf("query: unterminated string at position %d", i+1)
+			}
+			tokens = append(tokens, queryToken{kind: "string", text: b.String(), pos: i})
+			i = j + 1
+		case strings.ContainsRune("~=!<>", rune(c)):
+			op := ""
+			for _, o := range queryOperators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("query: invalid operator at position %d", i+1)
+			}
+			tokens = append(tokens, queryToken{kind: "op", text: op, pos: i})
+			i += len(op)
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n()\"~=!<>", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, queryToken{kind: "word", text: s[i:j], pos: i})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// queryParser is a recursive descent parser of the grammar:
+//
+//	expr    = and { "OR" and }
+//	and     = not { "AND" not }
+//	not     = "NOT" not | primary
+//	primary = "(" expr ")" | field op value | "cve" | word | string
+type queryParser struct {
+	tokens     []queryToken
+	pos        int
+	ignoreCase bool
+	// negated is whether the node being parsed is under an odd number of
+	// NOTs.
+	negated bool
+	q       *query
+}
+
+// parseQuery compiles a -query expression. Regular expressions in it,
+// including those of the "cve" keyword and the cve and lp fields, are
+// compiled with the "i" flag if ignoreCase is true.
+func parseQuery(s string, ignoreCase bool) (*query, error) {
+	tokens, err := tokenizeQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, ignoreCase: ignoreCase, q: &query{}}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("query: unexpected %q at position %d", t.text, t.pos+1)
+	}
+	p.q.root = root
+	return p.q, nil
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return queryToken{}, false
+}
+
+// peekKeyword returns whether the next token is the keyword like "AND".
+func (p *queryParser) peekKeyword(keyword string) bool {
+	t, ok := p.peek()
+	return ok && t.kind == "word" && t.text == keyword
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = queryOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = queryAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (queryNode, error) {
+	if p.peekKeyword("NOT") {
+		p.pos++
+		p.negated = !p.negated
+		node, err := p.parseNot()
+		p.negated = !p.negated
+		if err != nil {
+			return nil, err
+		}
+		return queryNot{node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("query: unexpected end")
+	}
+	p.pos++
+	switch t.kind {
+	case "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := p.peek(); !ok || c.kind != ")" {
+			return nil, fmt.Errorf("query: missing ) for ( at position %d", t.pos+1)
+		}
+		p.pos++
+		return node, nil
+	case "string":
+		return p.textPredicate("text", t.text)
+	case "word":
+		if op, ok := p.peek(); ok && op.kind == "op" {
+			p.pos++
+			v, ok := p.peek()
+			if !ok || (v.kind != "word" && v.kind != "string") {
+				return nil, fmt.Errorf("query: missing value after %s at position %d", op.text, op.pos+1)
+			}
+			p.pos++
+			return p.comparison(t, op, v.text)
+		}
+		if t.text == "cve" {
+			re, err := p.compile(cveRegex.String())
+			if err != nil {
+				return nil, err
+			}
+			p.addTextPattern(re, false)
+			return matchText("text", re), nil
+		}
+		return p.textPredicate("text", t.text)
+	}
+	return nil, fmt.Errorf("query: unexpected %q at position %d", t.text, t.pos+1)
+}
+
+// textPredicate returns a predicate matching the regular expression
+// against the summary, details or both of them for field "text".
+func (p *queryParser) textPredicate(field, expr string) (queryNode, error) {
+	re, err := p.compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	p.addTextPattern(re, false)
+	return matchText(field, re), nil
+}
+
+// addTextPattern adds re to the text patterns unless the predicate
+// matching it is negated by NOT or by negated, which is true for
+// operators like "!~".
+func (p *queryParser) addTextPattern(re *regexp.Regexp, negated bool) {
+	if p.negated == negated {
+		p.q.textPatterns = append(p.q.textPatterns, re)
+	}
+}
+
+func (p *queryParser) compile(expr string) (*regexp.Regexp, error) {
+	if p.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("query: %s", err)
+	}
+	return re, nil
+}
+
+func matchText(field string, re *regexp.Regexp) queryNode {
+	return queryPredicate(func(_ *Entry, change *Change) bool {
+		switch field {
+		case "summary":
+			return re.MatchString(change.Summary)
+		case "detail":
+			for _, detail := range change.Details {
+				if detail.Matches(re) {
+					return true
+				}
+			}
+			return false
+		}
+		return change.Matches(re)
+	})
+}
+
+// comparison returns a predicate of `field op value`.
+func (p *queryParser) comparison(field, op queryToken, value string) (queryNode, error) {
+	invalidOp := fmt.Errorf("query: invalid operator %s for %s at position %d", op.text, field.text, op.pos+1)
+	negate := func(node queryNode) queryNode {
+		if op.text == "!~" || op.text == "!=" {
+			return queryNot{node}
+		}
+		return node
+	}
+	// Fields like "metadata.binary-only" are the values of the keys in
+	// the metadata.
+	name := field.text
+	metadataKey, ok := strings.CutPrefix(field.text, "metadata.")
+	if ok && metadataKey != "" {
+		name = "metadata"
+	}
+	switch name {
+	case "text", "summary", "detail":
+		if op.text != "~" && op.text != "!~" {
+			return nil, invalidOp
+		}
+		re, err := p.compile(value)
+		if err != nil {
+			return nil, err
+		}
+		p.addTextPattern(re, op.text == "!~")
+		return negate(matchText(field.text, re)), nil
+	case "package", "distribution", "maintainer", "urgency", "metadata":
+		get := func(entry *Entry) string {
+			switch name {
+			case "package":
+				return entry.Package
+			case "distribution":
+				return entry.Distributions
+			case "maintainer":
+				return entry.MaintainerName + " <" + entry.EmailAddress + ">"
+			case "metadata":
+				return entry.MetadataFields[strings.ToLower(metadataKey)]
+			}
+			return entry.Urgency
+		}
+		switch op.text {
+		case "~", "!~":
+			re, err := p.compile(value)
+			if err != nil {
+				return nil, err
+			}
+			return negate(queryPredicate(func(entry *Entry, _ *Change) bool {
+				return re.MatchString(get(entry))
+			})), nil
+		case "=", "!=":
+			if name == "distribution" {
+				series := strings.Split(value, ",")
+				return negate(queryPredicate(func(entry *Entry, _ *Change) bool {
+					return inSeries(entry.Distributions, series)
+				})), nil
+			}
+			return negate(queryPredicate(func(entry *Entry, _ *Change) bool {
+				return strings.EqualFold(get(entry), value)
+			})), nil
+		}
+		return nil, invalidOp
+	case "version":
+		if op.text == "~" || op.text == "!~" {
+			return nil, invalidOp
+		}
+		return queryPredicate(func(entry *Entry, _ *Change) bool {
+			return compareResult(changelog.CompareVersions(entry.Version, value), op.text)
+		}), nil
+	case "date":
+		if op.text == "~" || op.text == "!~" {
+			return nil, invalidOp
+		}
+		// A date without time is compared with the date of entries in
+		// their own timezones.
+		if _, err := time.Parse("2006-01-02", value); err == nil {
+			return queryPredicate(func(entry *Entry, _ *Change) bool {
+				return compareResult(strings.Compare(entry.Date.Format("2006-01-02"), value), op.text)
+			}), nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("query: invalid date %q, must be YYYY-MM-DD or RFC 3339", value)
+		}
+		return queryPredicate(func(entry *Entry, _ *Change) bool {
+			return compareResult(entry.Date.Compare(t), op.text)
+		}), nil
+	case "cve":
+		if op.text != "=" && op.text != "!=" {
+			return nil, invalidOp
+		}
+		ids, err := parseCVEList(value)
+		if err != nil {
+			return nil, fmt.Errorf("query: %s", err)
+		}
+		re, err := p.compile((&cveFlag{set: true, ids: ids}).regexp().String())
+		if err != nil {
+			return nil, err
+		}
+		p.addTextPattern(re, op.text == "!=")
+		return negate(matchText("text", re)), nil
+	case "lp":
+		if op.text != "=" && op.text != "!=" {
+			return nil, invalidOp
+		}
+		var bugs lpBugsFlag
+		if err := bugs.Set(value); err != nil {
+			return nil, fmt.Errorf("query: %s", err)
+		}
+		re, err := p.compile(bugs.regexp().String())
+		if err != nil {
+			return nil, err
+		}
+		p.addTextPattern(re, op.text == "!=")
+		return negate(matchText("text", re)), nil
+	}
+	return nil, fmt.Errorf("query: unknown field %q at position %d", field.text, field.pos+1)
+}
+
+// compareResult returns whether the result of a comparison function
+// satisfies op.
+func compareResult(c int, op string) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
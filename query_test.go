@@ -0,0 +1,172 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+func TestQueryTextPatternsSkipNegations(t *testing.T) {
+	for _, test := range []struct {
+		query string
+		want  string
+	}{
+		{`foo`, "foo"},
+		{`NOT foo`, ""},
+		{`NOT NOT foo`, "foo"},
+		{`bar AND NOT foo`, "bar"},
+		{`NOT (foo OR summary~bar) OR baz`, "baz"},
+		{`text !~ foo`, ""},
+		{`NOT detail !~ foo`, "foo"},
+		{`cve != CVE-2024-1234 AND lp = 2078100`, `LP:\s*(?:#\d+\s*,\s*)*#(?:2078100)\b`},
+	} {
+		q, err := parseQuery(test.query, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, re := range q.textPatterns {
+			got = append(got, re.String())
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("parseQuery(%q).textPatterns = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestQueryIgnoreCase(t *testing.T) {
+	change := Change{Summary: "fix cve-2024-1234 (lp: #2078100)"}
+	for _, s := range []string{`cve`, `cve = CVE-2024-1234`, `lp = 2078100`, `FIX`} {
+		q, err := parseQuery(s, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !q.root.eval(&Entry{}, &change) {
+			t.Errorf("parseQuery(%q, true) does not match %q", s, change.Summary)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	entry := Entry{
+		Package:        "linux",
+		Version:        "6.8.0-45.45",
+		Distributions:  "noble",
+		MaintainerName: "John Doe",
+		EmailAddress:   "john.doe@canonical.com",
+		Date:           time.Date(2024, 9, 10, 12, 0, 0, 0, time.UTC),
+		MetadataFields: map[string]string{"urgency": "medium"},
+		Urgency:        "medium",
+	}
+	change := Change{
+		Summary: `fix "memory leak" (LP: #2078100)`,
+		Details: []changelog.Detail{{Lines: []string{"CVE-2024-1234"}}},
+	}
+	for _, test := range []struct {
+		query string
+		want  bool
+	}{
+		// AND binds tighter than OR, and NOT tighter than AND.
+		{`leak OR foo AND bar`, true},
+		{`foo AND bar OR leak`, true},
+		{`NOT leak AND foo`, false},
+		{`NOT foo AND NOT bar`, true},
+		{`NOT NOT leak`, true},
+
+		// Parentheses.
+		{`(leak OR foo) AND bar`, false},
+		{`NOT (leak AND foo)`, true},
+		{`((leak))`, true},
+		{`(foo OR (bar OR leak)) AND fix`, true},
+
+		// Quoted strings are regular expressions like words, and keep
+		// spaces, keywords, parentheses and operators.
+		{`"memory leak"`, true},
+		{`"memory  leak"`, false},
+		{`"\"memory leak\""`, true},
+		{`"(LP: #2078100)"`, true},
+		{`"OR" OR "NOT"`, false},
+		{`"\\(LP: #2078100\\)"`, true},
+		{`"AND"`, false},
+		{`text ~ "fix \"memory"`, true},
+
+		// Field selectors.
+		{`package = linux`, true},
+		{`package = LINUX`, true},
+		{`package != linux`, false},
+		{`package ~ ^lin`, true},
+		{`package !~ ^lin`, false},
+		{`distribution = jammy,noble`, true},
+		{`distribution != noble`, false},
+		{`maintainer ~ "@canonical\\.com>$"`, true},
+		{`maintainer = "john doe <john.doe@canonical.com>"`, true},
+		{`urgency = medium`, true},
+		{`metadata.urgency = medium`, true},
+		{`metadata.binary-only = yes`, false},
+		{`version >= 6.8.0-9.9`, true},
+		{`version < 6.8.0-45.45`, false},
+		{`version = 6.8.0-45.45`, true},
+		{`version > "6.8.0-45.45~"`, true},
+		{`date = 2024-09-10`, true},
+		{`date < 2024-09-10`, false},
+		{`date > 2024-09-10T11:59:59Z`, true},
+		{`date <= 2024-09-10T21:00:00+09:00`, true},
+		{`summary ~ leak`, true},
+		{`detail ~ leak`, false},
+		{`detail ~ CVE`, true},
+		{`text !~ leak`, false},
+		{`cve`, true},
+		{`cve = CVE-2024-1234`, true},
+		{`cve != CVE-2024-1234`, false},
+		{`cve = CVE-2024-5678`, false},
+		{`lp = 2078100`, true},
+		{`lp != 2078100`, false},
+		{`package=linux AND version>=6.8.0-1.1`, true},
+	} {
+		q, err := parseQuery(test.query, false)
+		if err != nil {
+			t.Errorf("parseQuery(%q) failed: %s", test.query, err)
+			continue
+		}
+		if got := q.root.eval(&entry, &change); got != test.want {
+			t.Errorf("parseQuery(%q) = %t, want %t", test.query, got, test.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, test := range []struct {
+		query string
+		want  string
+	}{
+		{``, `query: unexpected end`},
+		{`foo AND`, `query: unexpected end`},
+		{`NOT`, `query: unexpected end`},
+		{`(foo`, `query: missing ) for ( at position 1`},
+		{`foo AND (bar OR (baz)`, `query: missing ) for ( at position 9`},
+		{`foo)`, `query: unexpected ")" at position 4`},
+		{`foo bar`, `query: unexpected "bar" at position 5`},
+		{`()`, `query: unexpected ")" at position 2`},
+		{`foo AND "bar`, `query: unterminated string at position 9`},
+		{`version > 6.8.0-45.45~`, `query: unexpected "~" at position 22`},
+		{`version ! 1`, `query: invalid operator at position 9`},
+		{`package =`, `query: missing value after = at position 9`},
+		{`package = (linux)`, `query: missing value after = at position 9`},
+		{`version ~ 1`, `query: invalid operator ~ for version at position 9`},
+		{`foo OR summary = x`, `query: invalid operator = for summary at position 16`},
+		{`cve < CVE-2024-1234`, `query: invalid operator < for cve at position 5`},
+		{`size > 1`, `query: unknown field "size" at position 1`},
+		{`metadata. = x`, `query: unknown field "metadata." at position 1`},
+		{`date > yesterday`, `query: invalid date "yesterday", must be YYYY-MM-DD or RFC 3339`},
+		{`text ~ "("`, "query: error parsing regexp: missing closing ): `(`"},
+	} {
+		_, err := parseQuery(test.query, false)
+		if err == nil {
+			t.Errorf("parseQuery(%q) succeeded, want error %q", test.query, test.want)
+		} else if err.Error() != test.want {
+			t.Errorf("parseQuery(%q) error = %q, want %q", test.query, err, test.want)
+		}
+	}
+}
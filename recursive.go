@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// findDocChangelogs returns changelog.Debian* files under root, like
+// /usr/share/doc/PACKAGE/changelog.Debian.gz. Symlinks are skipped since
+// they point to the changelog of another package, which is also found.
+func findDocChangelogs(root string) ([]string, error) {
+	var filenames []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.HasPrefix(d.Name(), "changelog.Debian") {
+			filenames = append(filenames, path)
+		}
+		return nil
+	})
+	return filenames, err
+}
+
+// docPackage returns the binary package owning the doc directory of
+// filename, which is the name of the directory.
+func docPackage(filename string) string {
+	return filepath.Base(filepath.Dir(filename))
+}
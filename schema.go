@@ -0,0 +1,61 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// entrySchema is the JSON Schema of an entry in the JSON outputs. Its $id
+// has the schema version, which is incremented on incompatible changes.
+//
+//go:embed schema/entry.schema.json
+var entrySchema []byte
+
+func runSchema(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	format := fs.String("format", "ndjson", `output format to describe, "ndjson" (or "jsonl") for an entry, or "json" for an array of entries`)
+	addErrorsFlag(fs)
+	fs.Parse(args)
+
+	switch *format {
+	case "ndjson", "jsonl":
+		_, err := os.Stdout.Write(entrySchema)
+		return err
+	case "json":
+		data, err := entriesSchema()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s\n", data)
+		return err
+	default:
+		return fmt.Errorf(`unknown format %q, must be "ndjson", "jsonl" or "json"`, *format)
+	}
+}
+
+// entriesSchema returns the schema of an array of entries made from
+// entrySchema. Definitions are moved to the top level so that references
+// to them are still valid.
+func entriesSchema() ([]byte, error) {
+	var entry map[string]any
+	if err := json.Unmarshal(entrySchema, &entry); err != nil {
+		return nil, err
+	}
+	schema := map[string]any{
+		"$schema":     entry["$schema"],
+		"$id":         strings.Replace(entry["$id"].(string), "entry.schema.json", "entries.schema.json", 1),
+		"title":       "Changelog entries",
+		"description": "An array of entries written by the json output format of " + appName + ".",
+		"type":        "array",
+		"$defs":       entry["$defs"],
+	}
+	for _, key := range []string{"$schema", "$id", "$defs", "description"} {
+		delete(entry, key)
+	}
+	schema["items"] = entry
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// sourceConfig is an external command registered in the config file to
+// act as a changelog source. The command is invoked with the package and
+// the series as the last two arguments, and must write the changelog to
+// stdout.
+type sourceConfig struct {
+	Command []string `json:"command"`
+}
+
+// commandReadCloser reads stdout of a command and waits for it on Close.
+type commandReadCloser struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+	// desc describes the command in errors, like "source command".
+	desc string
+	// ignoreSIGPIPE makes Close ignore the command killed by SIGPIPE,
+	// which happens when stdout is closed before reading all of it.
+	ignoreSIGPIPE bool
+}
+
+func (c *commandReadCloser) Close() error {
+	c.ReadCloser.Close()
+	if err := c.cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if c.ignoreSIGPIPE && errors.As(err, &exitErr) {
+			if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() && ws.Signal() == syscall.SIGPIPE {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s %s: %w", c.desc, c.cmd.Path, err)
+	}
+	return nil
+}
+
+// openSource runs the command of the named source and returns its stdout.
+func openSource(sources map[string]sourceConfig, name, pkg, series string) (io.ReadCloser, error) {
+	src, ok := sources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown source: %s", name)
+	}
+	if len(src.Command) == 0 {
+		return nil, fmt.Errorf("no command defined for source %s", name)
+	}
+	if pkg == "" {
+		return nil, errors.New("-package must be specified with -source")
+	}
+
+	args := append(append([]string(nil), src.Command[1:]...), pkg, series)
+	cmd := exec.Command(src.Command[0], args...)
+	cmd.Env = append(os.Environ(), "CHANGELOG_PACKAGE="+pkg, "CHANGELOG_SERIES="+series)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandReadCloser{ReadCloser: stdout, cmd: cmd, desc: "source command"}, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// spool keeps records for formats which write output after all entries.
+// Records are kept in memory until their total size exceeds maxMemory,
+// and then all of them are moved to a temporary file, which the records
+// added later are appended to. maxMemory 0 means no limit. The offsets
+// of the records are always kept in memory.
+type spool struct {
+	maxMemory int64
+	buf       bytes.Buffer
+	// file is the temporary file after spilling.
+	file *os.File
+	// offsets are the offsets of the records followed by the end of the
+	// last one.
+	offsets []int64
+}
+
+func newSpool(maxMemory int64) *spool {
+	return &spool{maxMemory: maxMemory, offsets: []int64{0}}
+}
+
+// newSpools returns n spools sharing maxMemory.
+func newSpools(maxMemory int64, n int) []*spool {
+	share := maxMemory / int64(n)
+	if maxMemory > 0 && share == 0 {
+		share = 1
+	}
+	spools := make([]*spool, n)
+	for i := range spools {
+		spools[i] = newSpool(share)
+	}
+	return spools
+}
+
+// add appends a copy of record.
+func (s *spool) add(record []byte) error {
+	if s.file != nil {
+		if _, err := s.file.Write(record); err != nil {
+			return fmt.Errorf("cannot spill to a temporary file: %w", err)
+		}
+	} else {
+		s.buf.Write(record)
+	}
+	s.offsets = append(s.offsets, s.size()+int64(len(record)))
+	if s.file == nil && s.maxMemory > 0 && int64(s.buf.Len()) > s.maxMemory {
+		return s.spill()
+	}
+	return nil
+}
+
+func (s *spool) spill() error {
+	f, err := os.CreateTemp("", appName+"-spool-*")
+	if err != nil {
+		return fmt.Errorf("cannot spill to a temporary file: %w", err)
+	}
+	// The file is removed at once so that it is not left behind if the
+	// output is aborted, and is kept until it is closed.
+	os.Remove(f.Name())
+	s.file = f
+	if _, err := s.file.Write(s.buf.Bytes()); err != nil {
+		return fmt.Errorf("cannot spill to a temporary file: %w", err)
+	}
+	s.buf = bytes.Buffer{}
+	return nil
+}
+
+// len returns the number of records.
+func (s *spool) len() int {
+	return len(s.offsets) - 1
+}
+
+// size returns the total size of the records.
+func (s *spool) size() int64 {
+	return s.offsets[len(s.offsets)-1]
+}
+
+// get returns the i-th record, which must not be modified.
+func (s *spool) get(i int) ([]byte, error) {
+	start, end := s.offsets[i], s.offsets[i+1]
+	if s.file == nil {
+		return s.buf.Bytes()[start:end], nil
+	}
+	record := make([]byte, end-start)
+	if _, err := s.file.ReadAt(record, start); err != nil {
+		return nil, fmt.Errorf("cannot read a temporary file: %w", err)
+	}
+	return record, nil
+}
+
+// WriteTo writes all records to w.
+func (s *spool) WriteTo(w io.Writer) (int64, error) {
+	if s.file == nil {
+		n, err := w.Write(s.buf.Bytes())
+		return int64(n), err
+	}
+	return io.Copy(w, io.NewSectionReader(s.file, 0, s.size()))
+}
+
+// close releases the temporary file if any.
+func (s *spool) close() error {
+	s.buf = bytes.Buffer{}
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// parseByteSize parses a size in bytes like "512", "64K", "256M" or "1G".
+func parseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSuffix(strings.TrimSuffix(value, "B"), "b"))
+	shift := 0
+	switch {
+	case strings.HasSuffix(s, "K"):
+		shift = 10
+	case strings.HasSuffix(s, "M"):
+		shift = 20
+	case strings.HasSuffix(s, "G"):
+		shift = 30
+	}
+	if shift > 0 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return n << shift, nil
+}
+
+// byteSizeFlag is a flag.Value of sizes parsed with parseByteSize.
+type byteSizeFlag int64
+
+func (f *byteSizeFlag) String() string {
+	return strconv.FormatInt(int64(*f), 10)
+}
+
+func (f *byteSizeFlag) Set(value string) error {
+	n, err := parseByteSize(value)
+	if err != nil {
+		return err
+	}
+	*f = byteSizeFlag(n)
+	return nil
+}
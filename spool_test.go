@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSpoolSpillsOverMaxMemory(t *testing.T) {
+	s := newSpool(10)
+	defer s.close()
+	var want []string
+	for i := 0; i < 5; i++ {
+		record := fmt.Sprintf("record %d\n", i)
+		if err := s.add([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, record)
+		if spilled := s.file != nil; spilled != (i >= 1) {
+			t.Errorf("after %d records: spilled = %v", i+1, spilled)
+		}
+	}
+	if s.len() != len(want) {
+		t.Fatalf("len() = %d, want %d", s.len(), len(want))
+	}
+	for i, w := range want {
+		got, err := s.get(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != w {
+			t.Errorf("get(%d) = %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for value, want := range map[string]int64{
+		"0":     0,
+		"512":   512,
+		"64K":   64 << 10,
+		"256M":  256 << 20,
+		"256mb": 256 << 20,
+		"1G":    1 << 30,
+	} {
+		got, err := parseByteSize(value)
+		if err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "M", "-1", "1T", "1.5G"} {
+		if _, err := parseByteSize(value); err == nil {
+			t.Errorf("parseByteSize(%q) succeeded, want an error", value)
+		}
+	}
+}
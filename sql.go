@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// sqlSchema creates normalized tables for entries. Tables are created only
+// if they do not exist, so output can be appended to an existing database.
+const sqlSchema = `CREATE TABLE IF NOT EXISTS entries (
+  id INTEGER PRIMARY KEY,
+  package TEXT NOT NULL,
+  version TEXT NOT NULL,
+  distributions TEXT NOT NULL,
+  metadata TEXT NOT NULL,
+  maintainer_name TEXT NOT NULL,
+  email_address TEXT NOT NULL,
+  date TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS changes (
+  id INTEGER PRIMARY KEY,
+  entry_id INTEGER NOT NULL REFERENCES entries(id),
+  position INTEGER NOT NULL,
+  summary TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS details (
+  id INTEGER PRIMARY KEY,
+  change_id INTEGER NOT NULL REFERENCES changes(id),
+  position INTEGER NOT NULL,
+  text TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS cves (
+  change_id INTEGER NOT NULL REFERENCES changes(id),
+  cve TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS entries_version ON entries(version);
+CREATE INDEX IF NOT EXISTS entries_date ON entries(date);
+CREATE INDEX IF NOT EXISTS changes_entry_id ON changes(entry_id);
+CREATE INDEX IF NOT EXISTS details_change_id ON details(change_id);
+CREATE INDEX IF NOT EXISTS cves_cve ON cves(cve);
+`
+
+// sqlWriter writes entries as SQL statements for SQLite in a transaction.
+// Dates are written in the RFC 3339 format in UTC so that they sort
+// chronologically as text, or as written if they cannot be parsed.
+type sqlWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func newSQLWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &sqlWriter{w: bufio.NewWriter(w)}
+}
+
+func sqlDate(entry Entry) string {
+	if entry.Date.IsZero() && entry.RawDate != "" {
+		return entry.RawDate
+	}
+	return entry.Date.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+func (s *sqlWriter) begin() {
+	if s.count == 0 {
+		s.w.WriteString("BEGIN;\n" + sqlSchema)
+	}
+}
+
+func (s *sqlWriter) WriteEntry(entry Entry) error {
+	s.begin()
+	s.count++
+	fmt.Fprintf(s.w, "INSERT INTO entries (package, version, distributions, metadata, maintainer_name, email_address, date) VALUES (%s, %s, %s, %s, %s, %s, %s);\n",
+		sqlQuote(entry.Package), sqlQuote(entry.Version), sqlQuote(entry.Distributions), sqlQuote(entry.Metadata),
+		sqlQuote(entry.MaintainerName), sqlQuote(entry.EmailAddress), sqlQuote(sqlDate(entry)))
+	for i, change := range entry.Changes {
+		fmt.Fprintf(s.w, "INSERT INTO changes (entry_id, position, summary) VALUES ((SELECT max(id) FROM entries), %d, %s);\n",
+			i, sqlQuote(change.Summary))
+		for j, detail := range change.Details {
+			fmt.Fprintf(s.w, "INSERT INTO details (change_id, position, text) VALUES ((SELECT max(id) FROM changes), %d, %s);\n",
+				j, sqlQuote(strings.Join(detail.Lines, " ")))
+		}
+		for _, cve := range uniqueSorted([]Entry{{Changes: []Change{change}}}, cveRegex.FindAllString) {
+			fmt.Fprintf(s.w, "INSERT INTO cves (change_id, cve) VALUES ((SELECT max(id) FROM changes), %s);\n", sqlQuote(cve))
+		}
+	}
+	return s.w.Flush()
+}
+
+func (s *sqlWriter) Close() error {
+	s.begin()
+	s.w.WriteString("COMMIT;\n")
+	return s.w.Flush()
+}
+
+// sqlQuote returns s as an SQL string literal.
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// commandWriteCloser writes to stdin of a command and waits for it on Close.
+type commandWriteCloser struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (c *commandWriteCloser) Close() error {
+	c.WriteCloser.Close()
+	if err := c.cmd.Wait(); err != nil {
+		return fmt.Errorf("output command %s: %w", c.cmd.Path, err)
+	}
+	return nil
+}
+
+// startOutputCommand starts a command which reads output from stdin.
+func startOutputCommand(command []string) (*commandWriteCloser, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start output command: %w", err)
+	}
+	return &commandWriteCloser{WriteCloser: stdin, cmd: cmd}, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// subsystemAliases maps names of kernel subsystems selectable with
+// -subsystem to source paths and prefixes of commit subjects like
+// "btrfs: fix ..." mentioning them.
+var subsystemAliases = map[string][]string{
+	"block":     {"block"},
+	"bluetooth": {"net/bluetooth", "drivers/bluetooth", "bluetooth"},
+	"bpf":       {"kernel/bpf", "bpf"},
+	"btrfs":     {"fs/btrfs", "btrfs"},
+	"drm":       {"drivers/gpu/drm", "drm"},
+	"ext4":      {"fs/ext4", "ext4"},
+	"kvm":       {"virt/kvm", "arch/x86/kvm", "arch/arm64/kvm", "kvm"},
+	"mm":        {"mm"},
+	"net":       {"net", "drivers/net", "netfilter"},
+	"nfs":       {"fs/nfs", "fs/nfsd", "nfs", "nfsd"},
+	"nvme":      {"drivers/nvme", "nvme"},
+	"sched":     {"kernel/sched", "sched"},
+	"scsi":      {"drivers/scsi", "scsi"},
+	"sound":     {"sound", "alsa", "asoc"},
+	"usb":       {"drivers/usb", "usb"},
+	"wifi":      {"drivers/net/wireless", "net/wireless", "net/mac80211", "wifi", "mac80211", "cfg80211"},
+	"xfs":       {"fs/xfs", "xfs"},
+}
+
+// subsystemNames returns the names of subsystemAliases in sorted order.
+func subsystemNames() []string {
+	names := make([]string, 0, len(subsystemAliases))
+	for name := range subsystemAliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// subsystemRegexp returns the regular expression matching paths like
+// "drivers/net/..." or subject prefixes like "net: " of the subsystems
+// separated by commas. Names other than aliases are source paths.
+func subsystemRegexp(subsystems []string) *regexp.Regexp {
+	var terms []string
+	for _, list := range subsystems {
+		for _, name := range strings.Split(list, ",") {
+			name = strings.Trim(strings.TrimSpace(name), "/")
+			if name == "" {
+				continue
+			}
+			paths, ok := subsystemAliases[strings.ToLower(name)]
+			if !ok {
+				paths = []string{name}
+			}
+			for _, path := range paths {
+				terms = append(terms, regexp.QuoteMeta(path))
+			}
+		}
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(terms, "|") + `)[/:]`)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// isTar reports whether br starts with a header of the ustar format,
+// which is also used by GNU tar.
+func isTar(br *bufio.Reader) bool {
+	head, _ := br.Peek(262)
+	return len(head) == 262 && string(head[257:262]) == "ustar"
+}
+
+// openTarChangelog returns a reader of debian/changelog in a source
+// tarball read from br, like a .debian.tar.xz or a .tar.gz with the
+// top-level directory. r is closed on Close.
+func openTarChangelog(br *bufio.Reader, r io.Closer) (io.ReadCloser, error) {
+	tr := tar.NewReader(br)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			r.Close()
+			return nil, errors.New("no debian/changelog in tarball")
+		}
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("read tarball: %w", err)
+		}
+		name := path.Clean(strings.TrimPrefix(h.Name, "./"))
+		if name != "debian/changelog" && !(strings.Count(name, "/") == 2 && strings.HasSuffix(name, "/debian/changelog")) {
+			continue
+		}
+		if h.Typeflag != tar.TypeReg {
+			r.Close()
+			return nil, fmt.Errorf("%s in tarball is not a regular file", name)
+		}
+		return &decompressedReadCloser{Reader: tr, closers: []io.Closer{r}}, nil
+	}
+}
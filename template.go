@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hnakamur/ubuntu-linux-changelog-filter/changelog"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// templateData is passed to templates selected with -format.
+type templateData struct {
+	Entries   []Entry
+	Generated time.Time
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	// formatDate formats a time, or the date of an entry which is as
+	// written if it cannot be parsed.
+	"formatDate": func(v any) (string, error) {
+		switch v := v.(type) {
+		case time.Time:
+			return v.Format(changelog.DateFormat), nil
+		case Entry:
+			return v.DateString(), nil
+		case *Entry:
+			return v.DateString(), nil
+		}
+		return "", fmt.Errorf("formatDate: unsupported type %T", v)
+	},
+	"markdown": changelog.EscapeMarkdown,
+	"cves": func(v any) []string {
+		return uniqueSorted(entriesOf(v), cveRegex.FindAllString)
+	},
+	"lpBugs": func(v any) []int {
+		list := newLPBugList()
+		for _, entry := range entriesOf(v) {
+			list.addEntry(entry)
+		}
+		return list.sorted()
+	},
+}
+
+// entriesOf returns v as a slice of entries. v must be an Entry or a []Entry.
+func entriesOf(v any) []Entry {
+	switch v := v.(type) {
+	case Entry:
+		return []Entry{v}
+	case *Entry:
+		return []Entry{*v}
+	case []Entry:
+		return v
+	default:
+		return nil
+	}
+}
+
+// uniqueSorted returns the unique strings found by find in summaries and
+// detail lines of entries in ascending order.
+func uniqueSorted(entries []Entry, find func(s string, n int) []string) []string {
+	seen := make(map[string]bool)
+	add := func(s string) {
+		for _, m := range find(s, -1) {
+			seen[m] = true
+		}
+	}
+	for _, entry := range entries {
+		for _, change := range entry.Changes {
+			add(change.Summary)
+			for _, detail := range change.Details {
+				for _, line := range detail.Lines {
+					add(line)
+				}
+			}
+		}
+	}
+	var found []string
+	for s := range seen {
+		found = append(found, s)
+	}
+	sort.Strings(found)
+	return found
+}
+
+// registerTemplateFormats registers the built-in templates and templates
+// in dir as output formats named after their filenames without the ".tmpl"
+// extension. Templates in dir take precedence over the built-in ones.
+func registerTemplateFormats(dir string) error {
+	builtins, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		return err
+	}
+	for _, e := range builtins {
+		data, err := builtinTemplates.ReadFile("templates/" + e.Name())
+		if err != nil {
+			return err
+		}
+		if err := registerTemplateFormat(strings.TrimSuffix(e.Name(), ".tmpl"), string(data)); err != nil {
+			return err
+		}
+	}
+
+	if dir == "" {
+		return nil
+	}
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if err := registerTemplateFormat(strings.TrimSuffix(filepath.Base(filename), ".tmpl"), string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func registerTemplateFormat(name, text string) error {
+	if _, ok := entryWriterFactory(name); ok && !templateFormats[name] {
+		return fmt.Errorf("template %s conflicts with built-in output format", name)
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("parse template %s: %s", name, err)
+	}
+	templateFormats[name] = true
+	entryWriterFactories[name] = func(w io.Writer, opts outputOptions) entryWriter {
+		return &templateWriter{w: w, tmpl: tmpl}
+	}
+	return nil
+}
+
+// registerEntryTemplate registers text as the "template" output format,
+// which executes the template for each matched entry with the Entry as
+// data. A newline is added after the output of each entry unless it
+// already ends with one.
+func registerEntryTemplate(text string) error {
+	tmpl, err := template.New("template").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("parse template: %s", err)
+	}
+	entryWriterFactories["template"] = func(w io.Writer, opts outputOptions) entryWriter {
+		return &entryTemplateWriter{w: w, tmpl: tmpl}
+	}
+	return nil
+}
+
+type entryTemplateWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+	buf  bytes.Buffer
+}
+
+func (t *entryTemplateWriter) WriteEntry(entry Entry) error {
+	t.buf.Reset()
+	if err := t.tmpl.Execute(&t.buf, entry); err != nil {
+		return err
+	}
+	if t.buf.Len() > 0 && !bytes.HasSuffix(t.buf.Bytes(), []byte("\n")) {
+		t.buf.WriteByte('\n')
+	}
+	_, err := t.w.Write(t.buf.Bytes())
+	return err
+}
+
+func (t *entryTemplateWriter) Close() error {
+	return nil
+}
+
+// templateFormats has names of output formats registered by
+// registerTemplateFormat.
+var templateFormats = make(map[string]bool)
+
+// templateWriter collects matched entries and renders them with a template.
+type templateWriter struct {
+	w       io.Writer
+	tmpl    *template.Template
+	entries []Entry
+}
+
+func (t *templateWriter) WriteEntry(entry Entry) error {
+	t.entries = append(t.entries, entry)
+	return nil
+}
+
+func (t *templateWriter) Close() error {
+	return t.tmpl.Execute(t.w, templateData{
+		Entries:   t.entries,
+		Generated: time.Now(),
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xlsxWriter writes a workbook with a sheet of flattened changes and
+// a sheet of CVE counts per version. The rows of the sheets are kept in
+// XML until all entries are written.
+type xlsxWriter struct {
+	w       io.Writer
+	changes *spool
+	summary *spool
+}
+
+func newXLSXWriter(w io.Writer, opts outputOptions) entryWriter {
+	spools := newSpools(opts.maxMemory, 2)
+	return &xlsxWriter{w: w, changes: spools[0], summary: spools[1]}
+}
+
+// xlsxSummaryHeader is the header of the summary sheet, whose changes and
+// cves columns are numbers.
+var xlsxSummaryHeader = []string{"package", "version", "date", "changes", "cves"}
+
+func (x *xlsxWriter) WriteEntry(entry Entry) error {
+	for _, row := range flattenEntry(entry) {
+		// Rows are numbered from 1 and the first row is the header.
+		if err := x.changes.add([]byte(xlsxRow(x.changes.len()+2, row, nil))); err != nil {
+			return err
+		}
+	}
+	return x.summary.add([]byte(xlsxRow(x.summary.len()+2, []string{
+		entry.Package,
+		entry.Version,
+		entry.DateString(),
+		strconv.Itoa(len(entry.Changes)),
+		strconv.Itoa(len(uniqueSorted([]Entry{entry}, cveRegex.FindAllString))),
+	}, []int{3, 4})))
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.changes.close()
+	defer x.summary.close()
+	zw := zip.NewWriter(x.w)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+	sheets := []struct {
+		name   string
+		header []string
+		rows   *spool
+	}{
+		{"xl/worksheets/sheet1.xml", flatHeader, x.changes},
+		{"xl/worksheets/sheet2.xml", xlsxSummaryHeader, x.summary},
+	}
+	for _, sheet := range sheets {
+		fw, err := zw.Create(sheet.name)
+		if err != nil {
+			return err
+		}
+		if err := writeXLSXSheet(fw, sheet.header, sheet.rows); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeXLSXSheet writes the XML of a worksheet with a bold header row
+// followed by rows written with xlsxRow.
+func writeXLSXSheet(w io.Writer, header []string, rows *spool) error {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	b.WriteString(`<row r="1">`)
+	for j, value := range header {
+		fmt.Fprintf(&b, `<c r="%s1" t="inlineStr" s="1"><is><t>%s</t></is></c>`, xlsxColumnName(j), xmlEscape(value))
+	}
+	b.WriteString(`</row>`)
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+	for i := 0; i < rows.len(); i++ {
+		row, err := rows.get(i)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, `</sheetData></worksheet>`)
+	return err
+}
+
+// xlsxRow returns the XML of the r-th row of a worksheet. Values in
+// numericColumns are written as numbers.
+func xlsxRow(r int, row []string, numericColumns []int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, r)
+	for j, value := range row {
+		ref := xlsxColumnName(j) + strconv.Itoa(r)
+		if containsInt(numericColumns, j) {
+			fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, xmlEscape(value))
+		} else {
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(value))
+		}
+	}
+	b.WriteString(`</row>`)
+	return b.String()
+}
+
+// xlsxColumnName returns the column name like "A", "Z" or "AA" for
+// the zero based index.
+func xlsxColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet2.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="Changes" sheetId="1" r:id="rId1"/><sheet name="Summary" sheetId="2" r:id="rId2"/></sheets>` +
+	`</workbook>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>` +
+	`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font/><font><b/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border/></borders>` +
+	`<cellStyleXfs count="1"><xf/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf/><xf fontId="1" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// xmlSchemaVersion is incremented when elements are renamed or removed
+// from the XML output.
+const xmlSchemaVersion = "1"
+
+// xmlWriter writes entries as <entry> elements in a <changelog> element.
+// Element names are the same as keys of the JSON output, and dates are
+// in the RFC 3339 format.
+type xmlWriter struct {
+	w     io.Writer
+	enc   *xml.Encoder
+	start xml.StartElement
+}
+
+func newXMLWriter(w io.Writer, opts outputOptions) entryWriter {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &xmlWriter{w: w, enc: enc}
+}
+
+func (x *xmlWriter) begin() error {
+	if x.start.Name.Local != "" {
+		return nil
+	}
+	if _, err := io.WriteString(x.w, xml.Header); err != nil {
+		return err
+	}
+	x.start = xml.StartElement{
+		Name: xml.Name{Local: "changelog"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "schema_version"}, Value: xmlSchemaVersion}},
+	}
+	return x.enc.EncodeToken(x.start)
+}
+
+func (x *xmlWriter) WriteEntry(entry Entry) error {
+	if err := x.begin(); err != nil {
+		return err
+	}
+	return x.enc.EncodeElement(entry, xml.StartElement{Name: xml.Name{Local: "entry"}})
+}
+
+func (x *xmlWriter) Close() error {
+	if err := x.begin(); err != nil {
+		return err
+	}
+	if err := x.enc.EncodeToken(x.start.End()); err != nil {
+		return err
+	}
+	if err := x.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(x.w, "\n")
+	return err
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// yamlWriter writes entries as a YAML sequence with the same keys as the
+// JSON output. Strings are written as double-quoted scalars, which are
+// JSON strings, so no escaping rules of YAML plain scalars are needed.
+type yamlWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func newYAMLWriter(w io.Writer, opts outputOptions) entryWriter {
+	return &yamlWriter{w: bufio.NewWriter(w)}
+}
+
+func (y *yamlWriter) WriteEntry(entry Entry) error {
+	y.count++
+	y.field("- ", "package", entry.Package)
+	y.field("  ", "version", entry.Version)
+	y.field("  ", "distributions", entry.Distributions)
+	y.field("  ", "metadata", entry.Metadata)
+	y.field("  ", "maintainer_name", entry.MaintainerName)
+	y.field("  ", "email_address", entry.EmailAddress)
+	if entry.Date.IsZero() && entry.RawDate != "" {
+		// The date as written if it cannot be parsed, like raw_date of
+		// the JSON output.
+		y.w.WriteString("  date: null\n")
+		y.field("  ", "raw_date", entry.RawDate)
+	} else {
+		y.field("  ", "date", entry.Date.Format(time.RFC3339))
+	}
+	if len(entry.Changes) == 0 {
+		y.w.WriteString("  changes: []\n")
+	} else {
+		y.w.WriteString("  changes:\n")
+	}
+	for _, change := range entry.Changes {
+		y.field("    - ", "summary", change.Summary)
+		if len(change.Details) == 0 {
+			y.w.WriteString("      details: []\n")
+			continue
+		}
+		y.w.WriteString("      details:\n")
+		for _, detail := range change.Details {
+			y.w.WriteString("        - lines:\n")
+			for _, line := range detail.Lines {
+				y.w.WriteString("            - " + yamlQuote(line) + "\n")
+			}
+		}
+	}
+	if entry.Filename != "" {
+		y.field("  ", "filename", entry.Filename)
+	}
+	if entry.BinaryPackage != "" {
+		y.field("  ", "binary_package", entry.BinaryPackage)
+	}
+	return y.w.Flush()
+}
+
+func (y *yamlWriter) field(prefix, key, value string) {
+	y.w.WriteString(prefix + key + ": " + yamlQuote(value) + "\n")
+}
+
+func (y *yamlWriter) Close() error {
+	if y.count == 0 {
+		y.w.WriteString("[]\n")
+	}
+	return y.w.Flush()
+}
+
+// yamlQuote returns s as a YAML double-quoted scalar.
+func yamlQuote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}